@@ -0,0 +1,188 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/scenario"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/session"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func hearingEvent(call, module string) nng.Event {
+	return nng.Event{Type: "hearing", My: call, Module: module, Protocol: "M17", Ur: "CQCQCQ", Rpt1: "SIMULATOR", Rpt2: "URFD " + module}
+}
+
+func closingEvent(call, module string) nng.Event {
+	return nng.Event{Type: "closing", My: call, Module: module}
+}
+
+func stateEvent(talkers ...nng.ActiveTalker) nng.Event {
+	return nng.Event{Type: "state", ActiveTalkers: talkers}
+}
+
+func ended(call string) func(nng.Event) bool {
+	return func(ev nng.Event) bool {
+		return ev.Type != "state_diff" && ev.My == call && ev.Status == "ended"
+	}
+}
+
+func TestE2ESession(t *testing.T) {
+	h := newHarness(t)
+	ws := h.dial("types=hearing")
+
+	h.publish(hearingEvent("KF8S", "A"))
+	active := ws.expect("an active hearing", func(ev nng.Event) bool {
+		return ev.Type == "hearing" && ev.My == "KF8S" && ev.Status == "active"
+	})
+	if active.ID == 0 || active.Protocol != "M17" {
+		t.Errorf("Expected hearing with ID and protocol, got %+v", active)
+	}
+
+	h.advance(7 * time.Second)
+	h.publish(closingEvent("KF8S", "A"))
+	closed := ws.expect("an ended closing", ended("KF8S"))
+	if closed.ID != active.ID || closed.Duration != 7 {
+		t.Errorf("Expected hearing %d to end after 7s, got %+v", active.ID, closed)
+	}
+
+	hearings := h.hearings()
+	if len(hearings) != 1 || hearings[0].Duration != 7 || hearings[0].My != "KF8S" {
+		t.Errorf("Expected one stored 7s hearing, got %+v", hearings)
+	}
+}
+
+// TestE2ENoClonedSessions sends traffic that must not store a transmission
+// more than once: repeated hearings, heartbeats from state and a module
+// correction.
+func TestE2ENoClonedSessions(t *testing.T) {
+	h := newHarness(t)
+	ws := h.dial("types=hearing")
+
+	talker := nng.ActiveTalker{Callsign: "KF8S", Module: "A", Protocol: "M17"}
+	h.publish(hearingEvent("KF8S", "A"))
+	for range 3 {
+		h.advance(2 * time.Second)
+		h.publish(hearingEvent("KF8S", "A"))
+		h.publish(stateEvent(talker))
+	}
+	talker.Module = "B"
+	h.advance(2 * time.Second)
+	h.publish(stateEvent(talker))
+	h.advance(2 * time.Second)
+	h.publish(closingEvent("KF8S", "B"))
+	closed := ws.expect("an ended closing", ended("KF8S"))
+
+	hearings := h.hearings()
+	if len(hearings) != 1 {
+		t.Fatalf("Expected one stored hearing, got %d: %+v", len(hearings), hearings)
+	}
+	if hr := hearings[0]; hr.ID != closed.ID || hr.Module != "B" || hr.Duration != 10 {
+		t.Errorf("Expected hearing %d on B lasting 10s, got %+v", closed.ID, hr)
+	}
+}
+
+func TestE2EMissingClosing(t *testing.T) {
+	h := newHarness(t)
+	ws := h.dial("types=hearing")
+
+	talker := nng.ActiveTalker{Callsign: "KF8S", Module: "A", Protocol: "M17"}
+	h.publish(hearingEvent("KF8S", "A"))
+	h.advance(5 * time.Second)
+	h.publish(stateEvent(talker))
+	h.advance(5 * time.Second)
+	h.publish(stateEvent())
+	if ev := ws.expect("a session ended by state", ended("KF8S")); ev.Duration != 10 {
+		t.Errorf("Expected 10s duration, got %v", ev.Duration)
+	}
+
+	// Without state events the safety net ends the session
+	h.publish(hearingEvent("W8CPT", "B"))
+	timeout := session.DefaultTimeout + time.Second
+	h.advance(timeout)
+	h.tick()
+	if ev := ws.expect("a timed out session", ended("W8CPT")); ev.Duration != timeout.Seconds() {
+		t.Errorf("Expected %v duration, got %v", timeout, ev.Duration)
+	}
+	if n := len(h.hearings()); n != 2 {
+		t.Errorf("Expected 2 stored hearings, got %d", n)
+	}
+}
+
+func TestE2EScenario(t *testing.T) {
+	sc, err := scenario.Load("../../examples/scenarios/edge-cases.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load scenario: %v", err)
+	}
+	h := newHarness(t)
+	start := h.clock()
+	for _, st := range sc.Timeline(start) {
+		h.advance(start.Add(st.At).Sub(h.clock()))
+		h.publish(st.Event)
+	}
+
+	if open := h.refl.tracker.Sessions(); len(open) != 0 {
+		t.Errorf("Expected every session closed, got %+v", open)
+	}
+	count := make(map[string]int)
+	for _, hr := range h.hearings() {
+		count[hr.My]++
+		switch hr.My {
+		case "KF8S":
+			if hr.Duration != 8 {
+				t.Errorf("Expected KF8S key-ups of 8s, got %v", hr.Duration)
+			}
+		case "W8CPT":
+			if hr.Duration != 6 {
+				t.Errorf("Expected W8CPT to last 6s, got %v", hr.Duration)
+			}
+		case "N8DBF":
+			// Without a closing event the 12s transmission is ended by
+			// the first state event missing it, which arrives at 42s.
+			if hr.Duration != 22 {
+				t.Errorf("Expected N8DBF to be ended after 22s, got %v", hr.Duration)
+			}
+		}
+	}
+	if count["KF8S"] != 3 || count["W8CPT"] != 1 || count["N8DBF"] != 1 {
+		t.Errorf("Expected 3 KF8S, 1 W8CPT and 1 N8DBF hearings, got %v", count)
+	}
+
+	var conns []store.Connection
+	h.get("/api/connections?callsign=N8DBF", &conns)
+	if len(conns) != 1 || conns[0].DisconnectedAt == nil {
+		t.Errorf("Expected one closed N8DBF connection, got %+v", conns)
+	}
+}
+
+func TestE2EState(t *testing.T) {
+	h := newHarness(t)
+
+	h.publish(nng.Event{
+		Type:    "state",
+		Clients: []nng.Client{{Callsign: "KF8S", Protocol: "DMR", OnModule: "A"}},
+		Modules: []nng.Module{{Name: "A", Description: "International"}},
+	})
+	var snapshot nng.Event
+	h.get("/api/state", &snapshot)
+	if len(snapshot.Clients) != 1 || snapshot.Clients[0].Callsign != "KF8S" {
+		t.Errorf("Expected state with KF8S linked, got %+v", snapshot)
+	}
+
+	// New clients are greeted with the link status and last snapshot
+	ws := h.dial("")
+	ws.expect("the link status", func(ev nng.Event) bool {
+		return ev.Type == "connection" && ev.Status == string(nng.StatusConnected)
+	})
+	ws.expect("the state snapshot", func(ev nng.Event) bool {
+		return ev.Type == "state" && len(ev.Modules) == 1
+	})
+
+	h.publish(nng.Event{Type: "client_disconnect", Callsign: "KF8S"})
+	var conns []store.Connection
+	h.get("/api/connections?kind=client", &conns)
+	if len(conns) != 1 || conns[0].DisconnectedAt == nil {
+		t.Errorf("Expected KF8S disconnected, got %+v", conns)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.nanomsg.org/mangos/v3"
+	"go.nanomsg.org/mangos/v3/protocol/pub"
+	_ "go.nanomsg.org/mangos/v3/transport/inproc"
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/config"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/logger"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/server"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+const (
+	// waitTimeout bounds every wait of the harness.
+	waitTimeout = 5 * time.Second
+	// writeInterval is how often the harness's batch writer flushes.
+	writeInterval = 10 * time.Millisecond
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// harness runs the dashboard pipeline of one reflector in process: events
+// published on an inproc PUB socket go through the real subscriber,
+// trackers and hub into a temporary SQLite database and an httptest server.
+// Hearings are queued through a batch writer as in production. The trackers
+// run on a fake clock moved by advance.
+type harness struct {
+	t       *testing.T
+	pub     mangos.Socket
+	store   *store.Store
+	writer  *store.BatchWriter
+	refl    *reflector
+	srv     *httptest.Server
+	handled chan nng.Event
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{
+		t:       t,
+		handled: make(chan nng.Event, 16),
+		now:     time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	url := "inproc://e2e/" + t.Name()
+
+	var err error
+	if h.pub, err = pub.NewSocket(); err != nil {
+		t.Fatalf("Failed to create pub socket: %v", err)
+	}
+	attached := make(chan struct{}, 1)
+	h.pub.SetPipeEventHook(func(ev mangos.PipeEvent, _ mangos.Pipe) {
+		if ev == mangos.PipeEventAttached {
+			select {
+			case attached <- struct{}{}:
+			default:
+			}
+		}
+	})
+	if err := h.pub.Listen(url); err != nil {
+		t.Fatalf("Failed to listen on %s: %v", url, err)
+	}
+
+	h.store, err = store.New(store.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "e2e.db")})
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if h.writer, err = store.NewBatchWriter(h.store, writeInterval, 100); err != nil {
+		t.Fatalf("Failed to create batch writer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := server.NewHub()
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
+
+	cfg := &config.Config{Reflectors: []config.ReflectorConfig{{NNGURL: url}}}
+	h.refl = newReflector(cfg.Reflectors[0], cfg, h.writer, h.store, hub, nil)
+	h.refl.tracker.Clock = h.clock
+	h.refl.links.Clock = h.clock
+	h.refl.sub.MinBackoff = 10 * time.Millisecond
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		_ = h.refl.sub.Listen(ctx, func(ev nng.Event) {
+			h.refl.handle(ev)
+			h.handled <- ev
+		})
+	}()
+
+	srv := server.NewServer(hub, fstest.MapFS{})
+	routes(srv, cfg, h.store, hub, []*reflector{h.refl})
+	h.srv = httptest.NewServer(srv.Handler())
+
+	t.Cleanup(func() {
+		h.srv.Close()
+		cancel()
+		<-listening
+		<-hubDone
+		_ = h.pub.Close()
+		_ = h.writer.Close()
+		_ = h.store.Close()
+	})
+
+	select {
+	case <-attached:
+	case <-time.After(waitTimeout):
+		t.Fatal("Timed out waiting for the subscriber to connect")
+	}
+	return h
+}
+
+func (h *harness) clock() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.now
+}
+
+// advance moves the fake clock forward by d.
+func (h *harness) advance(d time.Duration) {
+	h.mu.Lock()
+	h.now = h.now.Add(d)
+	h.mu.Unlock()
+}
+
+// publish sends ev from the reflector and waits until the dashboard has
+// handled it.
+func (h *harness) publish(ev nng.Event) {
+	h.t.Helper()
+	data, err := json.Marshal(ev)
+	if err != nil {
+		h.t.Fatalf("Failed to encode %s event: %v", ev.Type, err)
+	}
+	if err := h.pub.Send(data); err != nil {
+		h.t.Fatalf("Failed to publish %s event: %v", ev.Type, err)
+	}
+	select {
+	case <-h.handled:
+	case <-time.After(waitTimeout):
+		h.t.Fatalf("Timed out waiting for the %s event to be handled", ev.Type)
+	}
+}
+
+// tick runs the session safety net as the dashboard's ticker does.
+func (h *harness) tick() {
+	h.refl.tracker.Tick()
+}
+
+// flush waits until every queued hearing write is committed.
+func (h *harness) flush() {
+	h.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for h.writer.Pending() > 0 {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("Timed out with %d hearing writes pending", h.writer.Pending())
+		}
+		time.Sleep(writeInterval / 2)
+	}
+}
+
+// hearings returns the stored hearings, oldest first, once queued writes
+// are committed.
+func (h *harness) hearings() []store.Hearing {
+	h.t.Helper()
+	h.flush()
+	var out []store.Hearing
+	if err := h.store.EachHearing(store.HearingFilter{}, func(hr store.Hearing) error {
+		out = append(out, hr)
+		return nil
+	}); err != nil {
+		h.t.Fatalf("Failed to read hearings: %v", err)
+	}
+	return out
+}
+
+// get decodes the JSON response of an API path into v, once queued writes
+// are committed.
+func (h *harness) get(path string, v any) {
+	h.t.Helper()
+	h.flush()
+	resp, err := http.Get(h.srv.URL + path)
+	if err != nil {
+		h.t.Fatalf("GET %s failed: %v", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("Expected 200 from %s, got %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		h.t.Fatalf("Failed to decode %s: %v", path, err)
+	}
+}
+
+// wsClient is a websocket client of the harness.
+type wsClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// dial connects a websocket client with the given query, e.g.
+// "types=hearing".
+func (h *harness) dial(query string) *wsClient {
+	h.t.Helper()
+	url := "ws" + strings.TrimPrefix(h.srv.URL, "http") + "/ws?" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		h.t.Fatalf("Failed to dial %s: %v", url, err)
+	}
+	h.t.Cleanup(func() { _ = conn.Close() })
+	return &wsClient{t: h.t, conn: conn}
+}
+
+// expect reads messages until one matches and returns it, failing the test
+// if none arrives in time.
+func (c *wsClient) expect(desc string, match func(nng.Event) bool) nng.Event {
+	c.t.Helper()
+	deadline := time.Now().Add(waitTimeout)
+	for {
+		_ = c.conn.SetReadDeadline(deadline)
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("Expected %s, got %v", desc, err)
+		}
+		var ev nng.Event
+		if json.Unmarshal(data, &ev) == nil && match(ev) {
+			return ev
+		}
+	}
+}
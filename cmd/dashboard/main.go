@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/dbehnke/urfd-nng-dashboard/internal/api"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/assets"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/capture"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/config"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/logger"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/metrics"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/server"
 	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
@@ -35,6 +42,12 @@ func main() {
 		panic("Failed to load config: " + err.Error())
 	}
 
+	// Subcommands, e.g. "migrate status"
+	if flag.NArg() > 0 {
+		runCommand(cfg, flag.Args())
+		return
+	}
+
 	// 2. Initialize Logger
 	logCfg := logger.Config{
 		Level:      cfg.Logging.Level,
@@ -50,7 +63,7 @@ func main() {
 	}
 	logger.Log.Info("Config loaded",
 		zap.String("reflector_name", cfg.Reflector.Name),
-		zap.Int("configured_modules", len(cfg.Reflector.Modules)),
+		zap.Int("configured_modules", len(configuredModules(cfg.Reflectors, ""))),
 	)
 	defer logger.Sync()
 
@@ -60,316 +73,242 @@ func main() {
 		zap.String("date", Date),
 	)
 
+	// Cancelled on SIGINT/SIGTERM to begin a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// 3. Initialize Store
-	s, err := store.NewStore(cfg.Server.DBPath)
+	s, err := store.New(storeConfig(cfg))
 	if err != nil {
 		logger.Log.Fatal("Failed to initialize store", zap.Error(err))
 	}
 
+	// History retention
+	ret := cfg.Server.Retention
+	go s.RunRetention(ctx, store.RetentionPolicy{
+		MaxAge:          ret.MaxAge,
+		MaxRows:         ret.MaxRows,
+		Downsample:      ret.Downsample,
+		Interval:        ret.Interval,
+		CompactInterval: ret.CompactInterval,
+	})
+
 	// 4. Initialize Hub
+	// The hub outlives ctx so the final 'ended' broadcasts still go out.
 	hub := server.NewHub()
-	go hub.Run()
-
-	// State retention & Session management
-	var (
-		lastState nng.Event
-		stateMu   sync.RWMutex
-		sessions  = make(map[string]*ActiveSession)
-		sessMu    sync.Mutex
-	)
-
-	// Session cleanup and persistence ticker (Safety Net)
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hubDone := make(chan struct{})
 	go func() {
-		for range time.Tick(2 * time.Second) {
-			sessMu.Lock()
-			now := time.Now().UTC()
-			for key, sess := range sessions {
-				// Safety Net: Use a longer timeout (30s) if state messages are missing
-				if now.Sub(sess.LastSeen) > 30*time.Second {
-					// Session ended!
-					duration := now.Sub(sess.StartTime).Seconds()
-					if err := s.DB.Model(&store.Hearing{}).Where("id = ?", sess.ID).Update("duration", duration).Error; err != nil {
-						logger.Log.Error("Failed to update session duration", zap.Error(err))
-					}
-					hub.BroadcastJSON(nng.Event{
-						Type:      "hearing",
-						Status:    "ended",
-						ID:        sess.ID,
-						My:        sess.Callsign,
-						Module:    sess.Module,
-						Protocol:  sess.Protocol,
-						Ur:        sess.Ur,
-						Rpt2:      sess.Rpt2,
-						Duration:  duration,
-						CreatedAt: sess.StartTime.UTC(),
-					})
-					logger.Log.Info("Session timed out (safety net)", zap.Uint("id", sess.ID))
-					delete(sessions, key)
-				}
-			}
-			sessMu.Unlock()
-		}
+		hub.Run(hubCtx)
+		close(hubDone)
 	}()
 
-	// 5. Initialize NNG Subscriber
-	sub, err := nng.NewSubscriber(cfg.Server.NNGURL)
+	// Hearing writes are queued and batched unless write_interval is 0
+	var hearings store.HearingWriter = s
+	var writer *store.BatchWriter
+	if cfg.Database.WriteInterval > 0 {
+		writer, err = store.NewBatchWriter(s, cfg.Database.WriteInterval, cfg.Database.WriteBatch)
+		if err != nil {
+			logger.Log.Fatal("Failed to start hearing writer", zap.Error(err))
+		}
+		hearings = writer
+		metrics.GaugeFunc("db", "write_queue_depth", "Hearing writes waiting to be flushed.", func() float64 {
+			return float64(writer.Pending())
+		})
+	}
+
+	// State retention & Session management, per reflector
+	open, err := s.OpenConnections()
 	if err != nil {
-		logger.Log.Fatal("Failed to connect to NNG", zap.Error(err))
+		logger.Log.Error("Failed to load open connections", zap.Error(err))
+	}
+	var recorder *capture.Writer
+	if cc := cfg.Capture; cc.FilePath != "" {
+		recorder, err = capture.NewWriter(capture.Config{
+			FilePath:   cc.FilePath,
+			MaxSizeMB:  cc.MaxSizeMB,
+			MaxBackups: cc.MaxBackups,
+			MaxAgeDays: cc.MaxAgeDays,
+			Compress:   cc.Compress,
+		})
+		if err != nil {
+			logger.Log.Fatal("Failed to open capture file", zap.Error(err))
+		}
+		logger.Log.Info("Capturing reflector traffic", zap.String("file", cc.FilePath))
+	}
+	reflectors := make([]*reflector, len(cfg.Reflectors))
+	for i, rc := range cfg.Reflectors {
+		reflectors[i] = newReflector(rc, cfg, hearings, s, hub, open)
+		reflectors[i].capture = recorder
 	}
+	registerMetrics(hub, reflectors)
 
-	// 6. Listen for events
+	// Session cleanup and persistence ticker (Safety Net)
 	go func() {
-		if err := sub.Listen(func(ev nng.Event) {
-			// Pre-process: Trim spaces
-			ev.My = strings.TrimSpace(ev.My)
-			ev.Module = strings.TrimSpace(ev.Module)
-
-			// Log hearing events to DB (Session-aware)
-			if ev.Type == "hearing" || ev.Type == "closing" {
-				if ev.My == "" {
-					return
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, r := range reflectors {
+					r.tracker.Tick()
 				}
-				sessKey := ev.My + ":" + ev.Module
-				sessMu.Lock()
-
-				// Better matching: Find session by callsign if exact key fails
-				sess, exists := sessions[sessKey]
-				if !exists && ev.Type == "closing" {
-					// Search by callsign
-					for _, s := range sessions {
-						if s.Callsign == ev.My {
-							sess = s
-							exists = true
-							break
-						}
-					}
-				}
-
-				if ev.Type == "hearing" {
-					if !exists {
-						h := store.Hearing{
-							My:        ev.My,
-							Ur:        ev.Ur,
-							Rpt1:      ev.Rpt1,
-							Rpt2:      ev.Rpt2,
-							Module:    ev.Module,
-							Protocol:  ev.Protocol,
-							CreatedAt: time.Now().UTC(),
-						}
-						if err := s.DB.Create(&h).Error; err != nil {
-							logger.Log.Error("Failed to save hearing", zap.Error(err))
-						}
-						sess = &ActiveSession{
-							ID:        h.ID,
-							Callsign:  h.My,
-							Module:    h.Module,
-							Protocol:  h.Protocol,
-							Ur:        h.Ur,
-							Rpt2:      h.Rpt2,
-							StartTime: h.CreatedAt,
-							LastSeen:  time.Now().UTC(),
-						}
-						sessions[sessKey] = sess
-					} else {
-						sess.LastSeen = time.Now().UTC()
-					}
-					ev.ID = sess.ID
-					ev.Protocol = sess.Protocol
-					ev.CreatedAt = sess.StartTime.UTC()
-					ev.Status = "active"
-				} else if ev.Type == "closing" && exists {
-					duration := time.Since(sess.StartTime).Seconds()
-					if err := s.DB.Model(&store.Hearing{}).Where("id = ?", sess.ID).Update("duration", duration).Error; err != nil {
-						logger.Log.Error("Failed to update session duration", zap.Error(err))
-					}
-					ev.ID = sess.ID
-					ev.Status = "ended"
-					ev.Duration = duration
-					ev.CreatedAt = sess.StartTime.UTC()
-					ev.My = sess.Callsign
-					ev.Module = sess.Module
-					ev.Protocol = sess.Protocol
-					ev.Ur = sess.Ur
-					ev.Rpt2 = sess.Rpt2
-
-					// Clean up all sessions for this callsign to be safe
-					for k, s := range sessions {
-						if s.Callsign == ev.My {
-							delete(sessions, k)
-						}
-					}
-					logger.Log.Info("Session closed via closing event", zap.Uint("id", sess.ID))
-				}
-				sessMu.Unlock()
 			}
+		}
+	}()
 
-			if ev.Type == "state" {
-				stateMu.Lock()
-				lastState = ev
-				stateMu.Unlock()
-
-				sessMu.Lock()
-				activeTalkersByCall := make(map[string]nng.ActiveTalker)
-				for _, talker := range ev.ActiveTalkers {
-					call := strings.TrimSpace(talker.Callsign)
-					if call != "" {
-						talker.Module = strings.TrimSpace(talker.Module)
-						activeTalkersByCall[call] = talker
-					}
-				}
-
-				now := time.Now().UTC()
+	// 5. Listen for events from every reflector
+	var listening sync.WaitGroup
+	for _, r := range reflectors {
+		listening.Add(1)
+		go func() {
+			defer listening.Done()
+			if err := r.listen(ctx); err != nil {
+				logger.Log.Fatal("NNG subscriber failed", zap.String("reflector", r.cfg.ID), zap.Error(err))
+			}
+		}()
+	}
 
-				// A. Update/End existing sessions based on State
-				for key, sess := range sessions {
-					talker, ok := activeTalkersByCall[sess.Callsign]
-					if ok {
-						// User still talking. Correct module if needed.
-						if sess.Module != talker.Module {
-							logger.Log.Info("Correcting session module",
-								zap.String("callsign", sess.Callsign),
-								zap.String("old", sess.Module),
-								zap.String("new", talker.Module))
-							sess.Module = talker.Module
-							if err := s.DB.Model(&store.Hearing{}).Where("id = ?", sess.ID).Update("module", sess.Module).Error; err != nil {
-								logger.Log.Error("DB fix failed", zap.Error(err))
-							}
-							delete(sessions, key)
-							sessions[sess.Callsign+":"+sess.Module] = sess
-						}
-						sess.LastSeen = now
-						// Synthetic heartbeat
-						hub.BroadcastJSON(nng.Event{
-							Type:      "hearing",
-							Status:    "active",
-							ID:        sess.ID,
-							My:        sess.Callsign,
-							Ur:        sess.Ur,
-							Module:    sess.Module,
-							Rpt2:      sess.Rpt2,
-							Protocol:  sess.Protocol,
-							CreatedAt: sess.StartTime,
-						})
-					} else {
-						// User NOT in state.
-						// Give them a 3-second grace to allow 'closing' event to arrive or for state jitter
-						if now.Sub(sess.LastSeen) > 3*time.Second {
-							duration := now.Sub(sess.StartTime).Seconds()
-							if err := s.DB.Model(&store.Hearing{}).Where("id = ?", sess.ID).Update("duration", duration).Error; err != nil {
-								logger.Log.Error("Duration update failed", zap.Error(err))
-							}
-							hub.BroadcastJSON(nng.Event{
-								Type:      "hearing",
-								Status:    "ended",
-								ID:        sess.ID,
-								My:        sess.Callsign,
-								Module:    sess.Module,
-								Protocol:  sess.Protocol,
-								Ur:        sess.Ur,
-								Rpt2:      sess.Rpt2,
-								Duration:  duration,
-								CreatedAt: sess.StartTime.UTC(),
-							})
-							logger.Log.Info("Session ended via state sync", zap.Uint("id", sess.ID))
-							delete(sessions, key)
-						}
-					}
-				}
+	// 6. Start HTTP Server
+	srv := server.NewServer(hub, assets.GetAssets())
+	routes(srv, cfg, s, hub, reflectors)
 
-				// B. Recovery: Start missing sessions from State
-				for call, talker := range activeTalkersByCall {
-					found := false
-					for _, sess := range sessions {
-						if sess.Callsign == call {
-							found = true
-							break
-						}
-					}
-					if !found {
-						h := store.Hearing{
-							My:        call,
-							Module:    talker.Module,
-							Protocol:  talker.Protocol,
-							Ur:        "CQCQCQ",
-							Rpt1:      "SIMULATOR",
-							Rpt2:      "URFD " + talker.Module,
-							CreatedAt: now,
-						}
-						if err := s.DB.Create(&h).Error; err != nil {
-							logger.Log.Error("Recovery failed", zap.Error(err))
-						}
-						sessions[call+":"+talker.Module] = &ActiveSession{
-							ID:        h.ID,
-							Callsign:  h.My,
-							Module:    h.Module,
-							Protocol:  h.Protocol,
-							Ur:        h.Ur,
-							Rpt2:      h.Rpt2,
-							StartTime: h.CreatedAt,
-							LastSeen:  now,
-						}
-						logger.Log.Info("Recovered session from State", zap.String("callsign", call))
-					}
-				}
-				sessMu.Unlock()
-			}
+	logger.Log.Info("HTTP server starting", zap.String("addr", cfg.Server.Addr))
+	serveErr := srv.Start(ctx, cfg.Server.Addr)
+	if serveErr != nil {
+		logger.Log.Error("Server failed", zap.Error(serveErr))
+		stop()
+	}
 
-			hub.BroadcastJSON(ev)
-		}); err != nil {
-			logger.Log.Fatal("NNG subscriber failed", zap.Error(err))
+	// 7. Shutdown: stop ingesting, close open sessions, then drain clients
+	// and flush queued writes
+	logger.Log.Info("Shutting down")
+	listening.Wait()
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			logger.Log.Error("Failed to close capture file", zap.Error(err))
 		}
-	}()
+	}
+	for _, r := range reflectors {
+		r.tracker.CloseAll()
+	}
+	stopHub()
+	select {
+	case <-hubDone:
+	case <-time.After(server.ShutdownTimeout):
+		logger.Log.Warn("Timed out waiting for websocket clients to close")
+	}
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			logger.Log.Error("Failed to flush hearing writes", zap.Error(err))
+		}
+	}
+	if err := s.Close(); err != nil {
+		logger.Log.Error("Failed to close store", zap.Error(err))
+	}
+	logger.Log.Info("Shutdown complete")
+	if serveErr != nil {
+		// Fail so a supervisor restarts us; os.Exit skips the deferred Sync
+		logger.Sync()
+		os.Exit(1)
+	}
+}
 
-	// 7. Start HTTP Server
-	srv := server.NewServer(hub, assets.GetAssets())
+// routes registers the API, config, metrics and health endpoints on srv
+// and greets new websocket clients with the state of every reflector.
+func routes(srv *server.Server, cfg *config.Config, s *store.Store, hub *server.Hub, reflectors []*reflector) {
+	started := time.Now()
 
 	// API Routes
-	http.HandleFunc("/api/history", func(w http.ResponseWriter, r *http.Request) {
-		var hearings []store.Hearing
-		if err := s.DB.Order("id desc").Limit(50).Find(&hearings).Error; err != nil {
-			http.Error(w, err.Error(), 500)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(hearings); err != nil {
-			logger.Log.Error("Failed to encode history response", zap.Error(err))
+	apiHandler := api.New(s)
+	apiHandler.Modules = func(id string) map[string]string {
+		return configuredModules(cfg.Reflectors, id)
+	}
+	apiHandler.States = func() []nng.Event {
+		states := make([]nng.Event, len(reflectors))
+		for i, r := range reflectors {
+			states[i] = r.state.Snapshot()
 		}
-	})
+		return states
+	}
+	apiHandler.Register(srv.Mux)
 
-	http.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
+	srv.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
+		infos := make([]reflectorInfo, len(reflectors))
+		for i, r := range reflectors {
+			infos[i] = r.info()
+		}
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"version":   Version,
-			"commit":    Commit,
-			"date":      Date,
-			"reflector": cfg.Reflector,
+			"version":    Version,
+			"commit":     Commit,
+			"date":       Date,
+			"reflector":  cfg.Reflector,
+			"reflectors": infos,
+			"clients":    hub.ClientCount(),
+			"dropped":    hub.Dropped(),
 		}); err != nil {
 			logger.Log.Error("Failed to encode config response", zap.Error(err))
 		}
 	})
 
+	srv.Mux.Handle("GET /metrics", metrics.Handler())
+
+	health := &api.Health{}
+	for _, r := range reflectors {
+		name := "nng"
+		if len(reflectors) > 1 {
+			name += ":" + r.cfg.ID
+		}
+		health.Add(name, nngCheck(r.sub, started, cfg.Server.ReadyWindow))
+	}
+	health.Add("db", s.Ping)
+	health.Add("hub", hub.Ping)
+	health.Register(srv.Mux)
+
 	srv.OnConnect = func(client *server.Client) {
-		stateMu.RLock()
-		defer stateMu.RUnlock()
-		if lastState.Type != "" {
-			data, _ := json.Marshal(lastState)
-			client.Send <- data
+		for _, r := range reflectors {
+			r.greet(client)
 		}
 	}
+}
 
-	logger.Log.Info("HTTP server starting", zap.String("addr", cfg.Server.Addr))
-	if err := srv.Start(cfg.Server.Addr); err != nil {
-		logger.Log.Fatal("Server failed", zap.Error(err))
+// configuredModules returns the module descriptions of the reflector with
+// the given ID, or of every reflector for an empty ID. A module configured
+// on several reflectors keeps the description of the first.
+func configuredModules(reflectors []config.ReflectorConfig, id string) map[string]string {
+	modules := make(map[string]string)
+	for _, rc := range reflectors {
+		if id != "" && rc.ID != id {
+			continue
+		}
+		for name, desc := range rc.Modules {
+			if _, ok := modules[name]; !ok {
+				modules[name] = desc
+			}
+		}
+	}
+	return modules
+}
+
+// nngCheck fails readiness when nothing has arrived from the reflector
+// within window, counting from started until the first message.
+func nngCheck(sub *nng.Subscriber, started time.Time, window time.Duration) api.Check {
+	return func(context.Context) error {
+		last := sub.LastMessage()
+		if last.IsZero() {
+			last = started
+		}
+		if since := time.Since(last); window > 0 && since > window {
+			return fmt.Errorf("no message from reflector for %s", since.Round(time.Second))
+		}
+		return nil
 	}
 }
 
-type ActiveSession struct {
-	ID        uint
-	Callsign  string
-	Module    string
-	Protocol  string
-	Ur        string
-	Rpt2      string
-	StartTime time.Time
-	LastSeen  time.Time
+// storeConfig returns the database settings from cfg.
+func storeConfig(cfg *config.Config) store.Config {
+	return store.Config{Driver: cfg.Database.Driver, DSN: cfg.Database.DSN}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/metrics"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/server"
+)
+
+// registerMetrics exposes the hub and session trackers as scrape-time
+// gauges.
+func registerMetrics(hub *server.Hub, reflectors []*reflector) {
+	metrics.GaugeFunc("sessions", "active", "Transmissions in progress.", func() float64 {
+		n := 0
+		for _, r := range reflectors {
+			n += len(r.tracker.Sessions())
+		}
+		return float64(n)
+	})
+	metrics.GaugeFunc("hub", "clients", "Connected websocket and event stream clients.", func() float64 {
+		return float64(hub.ClientCount())
+	})
+	metrics.CounterFunc("hub", "dropped_messages_total", "Messages dropped because a client or the hub fell behind.", func() float64 {
+		return float64(hub.Dropped())
+	})
+}
+
+// recordState sets the linked gauges of the event's reflector from a state
+// snapshot.
+func recordState(ev nng.Event) {
+	metrics.Linked.DeletePartialMatch(prometheus.Labels{"reflector": ev.Reflector})
+	for _, c := range ev.Clients {
+		metrics.Linked.WithLabelValues(ev.Reflector, "client", strings.TrimSpace(c.OnModule)).Inc()
+	}
+	for _, u := range ev.Users {
+		metrics.Linked.WithLabelValues(ev.Reflector, "user", strings.TrimSpace(u.OnModule)).Inc()
+	}
+	for range ev.Peers {
+		metrics.Linked.WithLabelValues(ev.Reflector, "peer", "").Inc()
+	}
+}
+
+// observeWrite records the latency of a hearing write started at start.
+func observeWrite(op string, start time.Time) {
+	metrics.DBWriteSeconds.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/config"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+const migrateUsage = "usage: urfd-dashboard [-config path] migrate status|up"
+
+// runMigrate implements the migrate subcommand: "status" lists the schema
+// migrations and whether each is applied, "up" applies the pending ones.
+func runMigrate(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	s, err := store.Connect(storeConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+
+	switch args[0] {
+	case "status":
+		status, err := s.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range status {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05Z07:00")
+			}
+			_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return w.Flush()
+	case "up":
+		applied, err := s.Migrate()
+		for _, m := range applied {
+			_, _ = fmt.Fprintf(out, "Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			_, _ = fmt.Fprintln(out, "Schema is up to date")
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+// runCommand runs a subcommand instead of the dashboard.
+func runCommand(cfg *config.Config, args []string) {
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = runMigrate(cfg, args[1:], os.Stdout)
+	default:
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/capture"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/config"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/logger"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/server"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/session"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/state"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// reflector is the ingest pipeline of one reflector: its subscriber,
+// session and link trackers, and last state. Everything it broadcasts is
+// tagged with its ID.
+type reflector struct {
+	cfg     config.ReflectorConfig
+	sub     *nng.Subscriber
+	tracker *session.Tracker
+	links   *session.LinkTracker
+	state   state.Cache
+	hub     *server.Hub
+	// capture records every message received, if set
+	capture *capture.Writer
+}
+
+// newReflector wires up the pipeline for rc, resuming the connections in
+// open that belong to it.
+func newReflector(rc config.ReflectorConfig, cfg *config.Config, hearings store.HearingWriter, links session.LinkSink, hub *server.Hub, open []store.Connection) *reflector {
+	r := &reflector{cfg: rc, hub: hub}
+
+	_, queued := hearings.(*store.BatchWriter)
+	r.tracker = session.NewTracker(&sessionSink{hearings: hearings, hub: hub, timed: !queued})
+	r.tracker.Reflector = rc.ID
+
+	r.links = session.NewLinkTracker(links)
+	r.links.Reflector = rc.ID
+	var mine []store.Connection
+	for _, c := range open {
+		if c.Reflector == rc.ID {
+			mine = append(mine, c)
+		}
+	}
+	r.links.Load(mine)
+
+	r.sub = nng.NewSubscriber(rc.NNGURL)
+	r.sub.Reflector = rc.ID
+	r.sub.StaleTimeout = cfg.Server.NNGStaleTimeout
+	r.sub.OnMessage = r.record
+	r.sub.OnStatus = func(st nng.Status) {
+		logger.Log.Info("Reflector link status changed",
+			zap.String("reflector", rc.ID),
+			zap.String("url", rc.NNGURL),
+			zap.String("status", string(st)),
+		)
+		hub.BroadcastJSON(r.connectionEvent(st))
+	}
+	return r
+}
+
+// record appends a message to the capture, if capturing.
+func (r *reflector) record(msg []byte) {
+	if r.capture == nil {
+		return
+	}
+	if err := r.capture.Write(capture.NewRecord(time.Now().UTC(), r.cfg.ID, msg)); err != nil {
+		logger.Log.Warn("Failed to capture message", zap.String("reflector", r.cfg.ID), zap.Error(err))
+	}
+}
+
+// listen delivers the reflector's events until ctx is cancelled.
+func (r *reflector) listen(ctx context.Context) error {
+	return r.sub.Listen(ctx, r.handle)
+}
+
+func (r *reflector) handle(ev nng.Event) {
+	r.links.Handle(ev)
+	ev, ok := r.tracker.Handle(ev)
+	if !ok {
+		return
+	}
+
+	// State goes out as a diff; full snapshots only to new clients
+	if ev.Type == "state" {
+		recordState(ev)
+		if diff, changed := r.state.Update(ev); changed {
+			r.hub.BroadcastJSON(diff)
+		}
+		return
+	}
+
+	r.hub.BroadcastJSON(ev)
+}
+
+// greet sends a new client the link status and last state snapshot.
+func (r *reflector) greet(client *server.Client) {
+	client.SendJSON(r.connectionEvent(r.sub.Status()))
+	if snapshot := r.state.Snapshot(); snapshot.Type != "" {
+		client.SendJSON(snapshot)
+	}
+}
+
+// connectionEvent reports the reflector link status to websocket clients.
+func (r *reflector) connectionEvent(st nng.Status) nng.Event {
+	return nng.Event{Type: "connection", Status: string(st), Reflector: r.cfg.ID}
+}
+
+// reflectorInfo describes a reflector in /api/config.
+type reflectorInfo struct {
+	config.ReflectorConfig
+	NNGStatus nng.Status `json:"nng_status"`
+}
+
+func (r *reflector) info() reflectorInfo {
+	return reflectorInfo{ReflectorConfig: r.cfg, NNGStatus: r.sub.Status()}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/server"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// sessionSink persists tracked sessions to the hearing repository and
+// broadcasts the synthetic events of the tracker to websocket clients.
+// Writes are timed only when timed is set: a queued write returns before it
+// reaches the database, whose latency the batch writer records instead.
+type sessionSink struct {
+	hearings store.HearingWriter
+	hub      *server.Hub
+	timed    bool
+}
+
+func (k *sessionSink) CreateHearing(h *store.Hearing) error {
+	defer k.observe("create", time.Now())
+	return k.hearings.CreateHearing(h)
+}
+
+func (k *sessionSink) UpdateModule(id uint, module string) error {
+	defer k.observe("update_module", time.Now())
+	return k.hearings.UpdateModule(id, module)
+}
+
+func (k *sessionSink) CloseHearing(id uint, duration float64) error {
+	defer k.observe("update_duration", time.Now())
+	return k.hearings.CloseHearing(id, duration)
+}
+
+func (k *sessionSink) observe(op string, start time.Time) {
+	if k.timed {
+		observeWrite(op, start)
+	}
+}
+
+func (k *sessionSink) Broadcast(ev nng.Event) {
+	k.hub.BroadcastJSON(ev)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"math/rand"
 	"time"
@@ -16,8 +17,17 @@ import (
 var (
 	url      = flag.String("url", "tcp://127.0.0.1:5555", "NNG publish URL")
 	duration = flag.Duration("duration", 60*time.Minute, "Simulation duration")
+	nodes    = flag.Int("nodes", 5, "Number of client nodes kept linked")
+	peers    = flag.Int("peers", 1, "Number of peer reflectors kept linked")
+	churn    = flag.Float64("churn", 0.005, "Average node links and unlinks per second, may exceed 1 for load tests; peers churn five times less")
 )
 
+// realCalls are the callsigns heard talking. Nodes beyond the list get
+// generated callsigns.
+var realCalls = []string{"KF8S", "KI5RNN", "W8CPT", "W8EAP", "KE8VSI", "KZ8Z", "KE8RUH", "N8DBF", "KF8DRC", "W8FU", "W8VD", "K8PR", "KC8KJO", "KE8TFM", "WT8X", "AD8OD"}
+
+var protocols = []string{"DMR", "YSF", "M17", "P25", "D-Star"}
+
 type NodeState struct {
 	Callsign    string
 	Protocol    string
@@ -28,9 +38,16 @@ type NodeState struct {
 type UserState struct {
 	Callsign  string
 	Module    string
+	ViaPeer   string
 	LastHeard time.Time
 }
 
+type PeerState struct {
+	Callsign    string
+	Protocol    string
+	ConnectTime time.Time
+}
+
 type ModuleState string
 
 const (
@@ -68,9 +85,23 @@ func main() {
 		}
 	}()
 
+	if *replayPath != "" {
+		if err := replay(sock); err != nil {
+			log.Printf("Replay failed: %v", err)
+		}
+		return
+	}
+	if *scenarioPath != "" {
+		if err := runScenario(sock); err != nil {
+			log.Printf("Scenario failed: %v", err)
+		}
+		return
+	}
+
 	log.Printf("Simulator started on %s for %v", *url, *duration)
 
-	nodes := make(map[string]*NodeState)
+	nodeStates := make(map[string]*NodeState)
+	peerStates := make(map[string]*PeerState)
 	users := make(map[string]*UserState)
 
 	modules := map[string]*SimulatorModule{
@@ -79,26 +110,41 @@ func main() {
 		"C": {Name: "C", State: StateIdle},
 	}
 
-	// Initialize realistic users
-	realCalls := []string{"KF8S", "KI5RNN", "W8CPT", "W8EAP", "KE8VSI", "KZ8Z", "KE8RUH", "N8DBF", "KF8DRC", "W8FU", "W8VD", "K8PR", "KC8KJO", "KE8TFM", "WT8X", "AD8OD"}
-	for _, call := range realCalls {
-		users[call] = &UserState{
+	// Initialize some peers
+	now := time.Now().UTC()
+	for i := range *peers {
+		call := peerCallsign(i)
+		peerStates[call] = &PeerState{
+			Callsign:    call,
+			Protocol:    "D-Extra",
+			ConnectTime: now.Add(-time.Duration(1+rand.Intn(48)) * time.Hour),
+		}
+	}
+
+	// Initialize realistic users, some heard through the peers
+	for i, call := range realCalls {
+		u := &UserState{
 			Callsign:  call,
 			Module:    string(rune('A' + rand.Intn(3))),
-			LastHeard: time.Now().UTC().Add(-time.Duration(rand.Intn(60)) * time.Minute),
+			LastHeard: now.Add(-time.Duration(rand.Intn(60)) * time.Minute),
 		}
+		if *peers > 0 && i%2 == 0 {
+			u.ViaPeer = peerCallsign(rand.Intn(*peers))
+		}
+		users[call] = u
 	}
 
 	// Initialize some nodes
-	for i := 1; i <= 5; i++ {
-		call := realCalls[i%len(realCalls)]
-		nodes[call] = &NodeState{
+	for i := range *nodes {
+		call := nodeCallsign(i)
+		nodeStates[call] = &NodeState{
 			Callsign:    call,
-			Protocol:    "DMR",
+			Protocol:    protocols[rand.Intn(len(protocols))],
 			Module:      string(rune('A' + rand.Intn(3))),
-			ConnectTime: time.Now().UTC().Add(-time.Duration(rand.Intn(100)) * time.Minute),
+			ConnectTime: now.Add(-time.Duration(rand.Intn(100)) * time.Minute),
 		}
 	}
+	log.Printf("Simulating %d nodes and %d peers", len(nodeStates), len(peerStates))
 
 	stop := time.After(*duration)
 	ticker := time.NewTicker(1 * time.Second)
@@ -142,31 +188,17 @@ func main() {
 				}
 			}
 
-			// Randomly churn nodes
-			if rand.Float32() < 0.005 {
-				if len(nodes) > 3 && rand.Float32() < 0.5 {
-					var dropCall string
-					for c := range nodes {
-						dropCall = c
-						break
-					}
-					delete(nodes, dropCall)
-					log.Printf("Node %s disconnected", dropCall)
-				} else {
-					call := realCalls[rand.Intn(len(realCalls))]
-					nodes[call] = &NodeState{
-						Callsign:    call,
-						Protocol:    "D-Extra",
-						Module:      string(rune('A' + rand.Intn(3))),
-						ConnectTime: now,
-					}
-					log.Printf("Node %s connected", call)
-				}
+			// Randomly churn nodes and peers
+			for range occurrences(*churn) {
+				churnNode(sock, nodeStates, now)
+			}
+			for range occurrences(*churn / 5) {
+				churnPeer(peerStates, now)
 			}
 
 			// Periodic state broadcast
 			if now.Unix()%10 == 0 {
-				sendState(sock, nodes, users)
+				sendState(sock, nodeStates, peerStates, users, modules)
 			}
 		}
 	}
@@ -192,7 +224,6 @@ func startNewTalker(m *SimulatorModule, users map[string]*UserState, modules map
 		return
 	}
 
-	protocols := []string{"DMR", "YSF", "M17", "P25", "D-Star"}
 	proto := protocols[rand.Intn(len(protocols))]
 
 	sourceTalker := candidates[rand.Intn(len(candidates))]
@@ -207,6 +238,94 @@ func startNewTalker(m *SimulatorModule, users map[string]*UserState, modules map
 	users[sourceTalker].LastHeard = now
 }
 
+// occurrences returns how many times something happening rate times per
+// second on average happens in this second.
+func occurrences(rate float64) int {
+	n := int(rate)
+	if rand.Float64() < rate-float64(n) {
+		n++
+	}
+	return n
+}
+
+// nodeCallsign is the callsign of the i-th node: a real callsign while
+// they last, then a generated one.
+func nodeCallsign(i int) string {
+	if i < len(realCalls) {
+		return realCalls[i]
+	}
+	return fmt.Sprintf("SIM%04d", i)
+}
+
+func peerCallsign(i int) string {
+	return fmt.Sprintf("XLX%03d", 262+i)
+}
+
+// churnNode links or unlinks a node, unlinking more often the more nodes
+// there are so the count stays around -nodes.
+func churnNode(sock mangos.Socket, nodeStates map[string]*NodeState, now time.Time) {
+	target := *nodes
+	if target <= 0 {
+		return
+	}
+	if len(nodeStates) > 0 && rand.Float64() < float64(len(nodeStates))/float64(2*target) {
+		for call, n := range nodeStates {
+			delete(nodeStates, call)
+			log.Printf("Node %s disconnected from %s", call, n.Module)
+			sendClientEvent(sock, "client_disconnect", n)
+			return
+		}
+	}
+
+	call := nodeCallsign(rand.Intn(2 * max(target, len(realCalls))))
+	if _, linked := nodeStates[call]; linked {
+		return
+	}
+	n := &NodeState{
+		Callsign:    call,
+		Protocol:    protocols[rand.Intn(len(protocols))],
+		Module:      string(rune('A' + rand.Intn(3))),
+		ConnectTime: now,
+	}
+	nodeStates[call] = n
+	log.Printf("Node %s connected to %s", call, n.Module)
+	sendClientEvent(sock, "client_connect", n)
+}
+
+// churnPeer drops or links a peer the same way. Peers only show in state.
+func churnPeer(peerStates map[string]*PeerState, now time.Time) {
+	target := *peers
+	if target <= 0 {
+		return
+	}
+	if len(peerStates) > 0 && rand.Float64() < float64(len(peerStates))/float64(2*target) {
+		for call := range peerStates {
+			delete(peerStates, call)
+			log.Printf("Peer %s disconnected", call)
+			return
+		}
+	}
+
+	call := peerCallsign(rand.Intn(2 * target))
+	if _, linked := peerStates[call]; !linked {
+		peerStates[call] = &PeerState{Callsign: call, Protocol: "D-Extra", ConnectTime: now}
+		log.Printf("Peer %s connected", call)
+	}
+}
+
+func sendClientEvent(sock mangos.Socket, typ string, n *NodeState) {
+	ev := nng.Event{
+		Type:     typ,
+		Callsign: n.Callsign,
+		Module:   n.Module,
+		Protocol: n.Protocol,
+	}
+	data, _ := json.Marshal(ev)
+	if err := sock.Send(data); err != nil {
+		log.Printf("Failed to send %s: %v", typ, err)
+	}
+}
+
 func sendHearing(sock mangos.Socket, module, callsign, protocol string) {
 	ev := nng.Event{
 		Type:     "hearing",
@@ -236,11 +355,20 @@ func sendClosing(sock mangos.Socket, module, callsign, protocol string) {
 	}
 }
 
-func sendState(sock mangos.Socket, nodes map[string]*NodeState, users map[string]*UserState) {
+func sendState(sock mangos.Socket, nodeStates map[string]*NodeState, peerStates map[string]*PeerState, users map[string]*UserState, modules map[string]*SimulatorModule) {
 	ev := nng.Event{
 		Type: "state",
 	}
-	for _, n := range nodes {
+	for _, m := range modules {
+		if m.ActiveTalker != nil {
+			ev.ActiveTalkers = append(ev.ActiveTalkers, nng.ActiveTalker{
+				Callsign: m.ActiveTalker.Callsign,
+				Module:   m.Name,
+				Protocol: m.ActiveTalker.Protocol,
+			})
+		}
+	}
+	for _, n := range nodeStates {
 		ev.Clients = append(ev.Clients, nng.Client{
 			Callsign:    n.Callsign,
 			Protocol:    n.Protocol,
@@ -253,14 +381,16 @@ func sendState(sock mangos.Socket, nodes map[string]*NodeState, users map[string
 			Callsign:  u.Callsign,
 			OnModule:  u.Module,
 			LastHeard: u.LastHeard,
-			ViaPeer:   "XLX262",
+			ViaPeer:   u.ViaPeer,
+		})
+	}
+	for _, p := range peerStates {
+		ev.Peers = append(ev.Peers, nng.Peer{
+			Callsign:    p.Callsign,
+			Protocol:    p.Protocol,
+			ConnectTime: p.ConnectTime,
 		})
 	}
-	ev.Peers = append(ev.Peers, nng.Peer{
-		Callsign:    "XLX262",
-		Protocol:    "D-Extra",
-		ConnectTime: time.Now().UTC().Add(-24 * time.Hour),
-	})
 
 	// Sample Modules
 	ev.Modules = []nng.Module{
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"go.nanomsg.org/mangos/v3"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/capture"
+)
+
+var (
+	replayPath = flag.String("replay", "", "Republish a capture file instead of simulating")
+	speed      = flag.Float64("speed", 1, "Replay or scenario speed: 1 keeps the original timing, 0 sends as fast as possible")
+	step       = flag.Bool("step", false, "Replay one message per Enter key press")
+	reflector  = flag.String("reflector", "", "Replay only this reflector's messages")
+	wait       = flag.Duration("wait", 2*time.Second, "Time for subscribers to connect before a replay or scenario")
+)
+
+// drainTime lets the last messages reach subscribers before the socket is
+// closed, which discards anything still queued.
+const drainTime = time.Second
+
+// replay republishes the capture at *replayPath on sock.
+func replay(sock mangos.Socket) error {
+	f, err := os.Open(*replayPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := capture.ReplayOptions{Speed: *speed, Reflector: *reflector}
+	if *step {
+		stdin := bufio.NewReader(os.Stdin)
+		opts.Step = func(rec capture.Record) error {
+			fmt.Printf("%s %s %s [Enter]", rec.Received.Format(time.RFC3339Nano), rec.Reflector, describe(rec.Bytes()))
+			_, err := stdin.ReadString('\n')
+			return err
+		}
+	} else {
+		log.Printf("Waiting %v for subscribers", *wait)
+		select {
+		case <-time.After(*wait):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	log.Printf("Replaying %s at speed %v", *replayPath, *speed)
+	n, err := capture.Replay(ctx, capture.NewReader(f), sock.Send, opts)
+	log.Printf("Replayed %d messages", n)
+	time.Sleep(drainTime)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// describe summarises a message for the stepwise prompt.
+func describe(msg []byte) string {
+	var ev struct {
+		Type   string `json:"type"`
+		My     string `json:"my"`
+		Module string `json:"module"`
+	}
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		return "malformed message"
+	}
+	if ev.My != "" {
+		return fmt.Sprintf("%s %s on %s", ev.Type, ev.My, ev.Module)
+	}
+	return ev.Type
+}
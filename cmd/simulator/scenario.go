@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"go.nanomsg.org/mangos/v3"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/scenario"
+)
+
+var scenarioPath = flag.String("scenario", "", "Play a YAML or JSON scenario file instead of simulating")
+
+// runScenario plays the scenario at *scenarioPath on sock.
+func runScenario(sock mangos.Socket) error {
+	sc, err := scenario.Load(*scenarioPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("Waiting %v for subscribers", *wait)
+	select {
+	case <-time.After(*wait):
+	case <-ctx.Done():
+		return nil
+	}
+
+	steps := sc.Timeline(time.Now())
+	log.Printf("Playing scenario %q (%d events) at speed %v", sc.Name, len(steps), *speed)
+	n, err := scenario.Run(ctx, steps, sock.Send, scenario.RunOptions{Speed: *speed})
+	log.Printf("Sent %d messages", n)
+	time.Sleep(drainTime)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -1,2 +1,3 @@
 #!/bin/sh
 systemctl daemon-reload
+systemctl enable --now urfd-dashboard-healthcheck.timer
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// API serves the JSON endpoints backed by the store.
+type API struct {
+	// Hearings serves history and statistics; New sets it to the store.
+	Hearings store.HearingRepository
+	// Store serves connection history.
+	Store *store.Store
+	// States returns the last state event of each reflector, in
+	// configuration order. Reflectors that have not sent one yet have an
+	// empty Type.
+	States func() []nng.Event
+	// Modules returns the module descriptions from the config of the
+	// reflector with the given ID, or of every reflector for an empty ID.
+	Modules func(reflector string) map[string]string
+}
+
+func New(s *store.Store) *API {
+	return &API{Hearings: s, Store: s}
+}
+
+// Register adds the API routes to mux.
+func (a *API) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/state", a.handleState)
+	mux.HandleFunc("GET /api/states", a.handleStates)
+	mux.HandleFunc("GET /api/history", a.handleHistory)
+	mux.HandleFunc("GET /api/export/hearings", a.handleExport)
+	mux.HandleFunc("GET /api/callsigns/{call}", a.handleCallsign)
+	mux.HandleFunc("GET /api/stats/modules", a.handleModuleStats)
+	mux.HandleFunc("GET /api/connections", a.handleConnections)
+}
+
+// handleState returns the last state snapshot of the reflector named by
+// the reflector parameter, or of the first reflector. Its seq tells clients
+// which state_diff messages it already includes.
+func (a *API) handleState(w http.ResponseWriter, r *http.Request) {
+	states := a.states(r)
+	if len(states) == 0 {
+		http.Error(w, "no state received yet", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, states[0])
+}
+
+// handleStates returns the last state snapshot of every reflector that has
+// sent one.
+func (a *API) handleStates(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.states(r))
+}
+
+// states returns the snapshots received so far, limited to the reflector
+// named by the reflector parameter if there is one.
+func (a *API) states(r *http.Request) []nng.Event {
+	out := []nng.Event{}
+	if a.States == nil {
+		return out
+	}
+	reflector := r.URL.Query().Get("reflector")
+	for _, ev := range a.States() {
+		if ev.Type != "" && (reflector == "" || ev.Reflector == reflector) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		zap.L().Error("Failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// CallsignProfile is everything the dashboard knows about one station.
+type CallsignProfile struct {
+	Callsign string `json:"callsign"`
+	store.CallsignActivity
+
+	// Clients and Users are the station's entries in the last state event
+	// of each reflector
+	Clients []nng.Client `json:"clients"`
+	Users   []nng.User   `json:"users"`
+}
+
+func (a *API) handleCallsign(w http.ResponseWriter, r *http.Request) {
+	call := strings.ToUpper(strings.TrimSpace(r.PathValue("call")))
+
+	activity, err := a.Hearings.CallsignActivity(call)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	p := CallsignProfile{
+		Callsign:         call,
+		CallsignActivity: activity,
+		Clients:          []nng.Client{},
+		Users:            []nng.User{},
+	}
+
+	for _, state := range a.states(r) {
+		for _, c := range state.Clients {
+			if sameStation(c.Callsign, call) {
+				p.Clients = append(p.Clients, c)
+			}
+		}
+		for _, u := range state.Users {
+			if sameStation(u.Callsign, call) {
+				p.Users = append(p.Users, u)
+			}
+		}
+	}
+
+	if p.Transmissions == 0 && len(p.Clients) == 0 && len(p.Users) == 0 {
+		http.Error(w, "callsign not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, p)
+}
+
+// sameStation matches a callsign from the reflector, which may carry a
+// module or SSID suffix such as "N7TAE  B" or "N7TAE-7", against call.
+func sameStation(reported, call string) bool {
+	base := strings.ToUpper(strings.TrimSpace(reported))
+	if i := strings.IndexAny(base, " -/"); i >= 0 {
+		base = base[:i]
+	}
+	return base == call
+}
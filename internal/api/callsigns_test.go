@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func TestCallsignProfile(t *testing.T) {
+	a, mux := newTestAPI(t)
+	a.States = func() []nng.Event {
+		return []nng.Event{{
+			Type:    "state",
+			Clients: []nng.Client{{Callsign: "KF8S  B", OnModule: "B"}, {Callsign: "W8CPT", OnModule: "A"}},
+			Users:   []nng.User{{Callsign: "KF8S", OnModule: "B"}, {Callsign: "N7TAE", OnModule: "C"}},
+		}}
+	}
+
+	base := time.Date(2025, 12, 27, 9, 30, 0, 0, time.UTC)
+	for _, h := range []store.Hearing{
+		{My: "KF8S", Module: "A", Protocol: "DMR", Duration: 10, CreatedAt: base},
+		{My: "KF8S", Module: "B", Protocol: "M17", Duration: 5, CreatedAt: base.Add(time.Hour)},
+		{My: "KF8S", Module: "B", Protocol: "DMR", Duration: 2.5, CreatedAt: base.Add(25 * time.Hour)},
+		{My: "W8CPT", Module: "A", Protocol: "YSF", Duration: 60, CreatedAt: base},
+	} {
+		if err := a.Store.DB.Create(&h).Error; err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/callsigns/kf8s", nil))
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var p CallsignProfile
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if p.Callsign != "KF8S" || p.Transmissions != 3 || p.TalkTime != 17.5 {
+		t.Errorf("Unexpected totals: %+v", p)
+	}
+	if !p.FirstHeard.Equal(base) || !p.LastHeard.Equal(base.Add(25*time.Hour)) {
+		t.Errorf("Unexpected first/last heard: %v %v", p.FirstHeard, p.LastHeard)
+	}
+	if len(p.Modules) != 2 || p.Modules[0] != "A" || p.Modules[1] != "B" {
+		t.Errorf("Unexpected modules: %v", p.Modules)
+	}
+	if len(p.Protocols) != 2 || p.Protocols[0] != "DMR" || p.Protocols[1] != "M17" {
+		t.Errorf("Unexpected protocols: %v", p.Protocols)
+	}
+	if p.Hours[9] != 1 || p.Hours[10] != 2 {
+		t.Errorf("Unexpected hours: %v", p.Hours)
+	}
+	if len(p.Clients) != 1 || p.Clients[0].OnModule != "B" || len(p.Users) != 1 {
+		t.Errorf("Unexpected state entries: %+v %+v", p.Clients, p.Users)
+	}
+
+	// Listed in state only
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/callsigns/N7TAE", nil))
+	if rec.Code != 200 {
+		t.Errorf("Expected status 200 for a user without hearings, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/callsigns/NOCALL", nil))
+	if rec.Code != 404 {
+		t.Errorf("Expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// handleConnections lists client and peer connection sessions, most recent
+// first. With since/until only sessions overlapping that range are listed,
+// so since=until=T answers who was linked at time T.
+func (a *API) handleConnections(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.ConnectionFilter{
+		Kind:      q.Get("kind"),
+		Reflector: q.Get("reflector"),
+		Callsign:  q.Get("callsign"),
+		Module:    q.Get("module"),
+		Limit:     defaultHistoryLimit,
+	}
+	if f.Kind != "" && f.Kind != store.KindClient && f.Kind != store.KindPeer {
+		http.Error(w, fmt.Sprintf("invalid kind: %q", f.Kind), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if f.Since, err = parseTime(q, "since"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Until, err = parseTime(q, "until"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			http.Error(w, fmt.Sprintf("invalid limit: %q", v), http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(f.Limit, maxHistoryLimit)
+	}
+
+	conns, err := a.Store.ListConnections(f)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, conns)
+}
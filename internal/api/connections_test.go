@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func TestConnections(t *testing.T) {
+	a, mux := newTestAPI(t)
+
+	base := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time { t := base.Add(time.Duration(h) * time.Hour); return &t }
+	for _, c := range []store.Connection{
+		{Kind: store.KindClient, Callsign: "KF8S", Module: "A", ConnectedAt: base, DisconnectedAt: at(1)},
+		{Kind: store.KindClient, Callsign: "KF8S", Module: "B", ConnectedAt: *at(1), DisconnectedAt: at(3)},
+		{Kind: store.KindClient, Callsign: "W8CPT", Module: "A", ConnectedAt: *at(2)},
+		{Kind: store.KindPeer, Reflector: "URF002", Callsign: "XLX262", ConnectedAt: base},
+	} {
+		if err := a.Store.DB.Create(&c).Error; err != nil {
+			t.Fatalf("Failed to create connection: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   int
+	}{
+		{name: "All", query: "", status: 200, want: 4},
+		{name: "Clients on A", query: "?kind=client&module=A", status: 200, want: 2},
+		{name: "Callsign", query: "?callsign=kf8s", status: 200, want: 2},
+		{name: "Linked at Time", query: "?since=2025-12-27T14:30:00Z&until=2025-12-27T14:30:00Z", status: 200, want: 3},
+		{name: "Before Range", query: "?until=2025-12-27T00:00:00Z", status: 200, want: 0},
+		{name: "Reflector", query: "?reflector=URF002", status: 200, want: 1},
+		{name: "Bad Kind", query: "?kind=user", status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/connections"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+			var conns []store.Connection
+			if err := json.Unmarshal(rec.Body.Bytes(), &conns); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if len(conns) != tt.want {
+				t.Errorf("Expected %d connections, got %d: %+v", tt.want, len(conns), conns)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// Export formats
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// exportColumns is the CSV header, in the order of hearingRecord.
+var exportColumns = []string{"id", "created_at", "my", "ur", "rpt1", "rpt2", "module", "protocol", "duration", "reflector"}
+
+// handleExport streams every hearing matching the history filters, oldest
+// first, as CSV (the default) or newline-delimited JSON (format=ndjson).
+// Rows are written as they are read, so exports of any size use bounded
+// memory.
+func (a *API) handleExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = formatCSV
+	}
+	if format != formatCSV && format != formatNDJSON {
+		http.Error(w, fmt.Sprintf("invalid format: %q", format), http.StatusBadRequest)
+		return
+	}
+	f, err := parseHearingFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Headers go out with the first row; an error after that can only cut
+	// the export short.
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="hearings.%s"`, format))
+	if format == formatCSV {
+		err = exportCSV(w, a.Hearings, f)
+	} else {
+		err = exportNDJSON(w, a.Hearings, f)
+	}
+	if err != nil {
+		zap.L().Error("Failed to export hearings", zap.String("format", format), zap.Error(err))
+	}
+}
+
+func exportCSV(w http.ResponseWriter, hearings store.HearingRepository, f store.HearingFilter) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	err := hearings.EachHearing(f, func(h store.Hearing) error {
+		return cw.Write(hearingRecord(h))
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func exportNDJSON(w http.ResponseWriter, hearings store.HearingRepository, f store.HearingFilter) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	return hearings.EachHearing(f, func(h store.Hearing) error {
+		h.CreatedAt = h.CreatedAt.UTC()
+		return enc.Encode(h)
+	})
+}
+
+// hearingRecord formats h as a CSV row, with UTC timestamps.
+func hearingRecord(h store.Hearing) []string {
+	return []string{
+		strconv.FormatUint(uint64(h.ID), 10),
+		h.CreatedAt.UTC().Format(time.RFC3339),
+		h.My,
+		h.Ur,
+		h.Rpt1,
+		h.Rpt2,
+		h.Module,
+		h.Protocol,
+		strconv.FormatFloat(h.Duration, 'f', -1, 64),
+		h.Reflector,
+	}
+}
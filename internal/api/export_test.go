@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func TestExport(t *testing.T) {
+	a, mux := newTestAPI(t)
+	base := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	for i, call := range []string{"W8CPT", "KF8S", "W8EAP"} {
+		h := &store.Hearing{Reflector: "URF001", My: call, Module: "A", Protocol: "DMR", Duration: 1.5, CreatedAt: base.Add(time.Duration(i) * time.Hour)}
+		if err := a.Hearings.CreateHearing(h); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		want        []string
+	}{
+		{name: "CSV", query: "?my=W8*", status: 200, contentType: "text/csv; charset=utf-8", want: []string{"W8CPT", "W8EAP"}},
+		{name: "NDJSON", query: "?format=ndjson&since=2025-12-27T13:00:00Z", status: 200, contentType: "application/x-ndjson", want: []string{"KF8S", "W8EAP"}},
+		{name: "Ignores Limit", query: "?format=ndjson&limit=1", status: 200, contentType: "application/x-ndjson", want: []string{"W8CPT", "KF8S", "W8EAP"}},
+		{name: "Empty", query: "?module=Z", status: 200, contentType: "text/csv; charset=utf-8", want: nil},
+		{name: "Bad Format", query: "?format=xml", status: 400},
+		{name: "Bad Time", query: "?until=tomorrow", status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/export/hearings"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.contentType, ct)
+			}
+
+			var got []string
+			if strings.HasPrefix(tt.contentType, "text/csv") {
+				rows, err := csv.NewReader(rec.Body).ReadAll()
+				if err != nil {
+					t.Fatalf("Invalid CSV: %v", err)
+				}
+				if len(rows) == 0 || strings.Join(rows[0], ",") != strings.Join(exportColumns, ",") {
+					t.Fatalf("Expected header %v, got %v", exportColumns, rows)
+				}
+				for _, row := range rows[1:] {
+					if row[1] == "" || row[8] != "1.5" || row[9] != "URF001" {
+						t.Errorf("Expected a timestamp, duration 1.5 and reflector URF001, got %v", row)
+					}
+					got = append(got, row[2])
+				}
+			} else {
+				sc := bufio.NewScanner(rec.Body)
+				for sc.Scan() {
+					var h store.Hearing
+					if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+						t.Fatalf("Invalid JSON line %q: %v", sc.Text(), err)
+					}
+					got = append(got, h.My)
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// checkTimeout bounds each readiness check.
+const checkTimeout = 2 * time.Second
+
+// Check returns an error describing why a dependency is not ready.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Health serves liveness and readiness probes for process supervisors.
+type Health struct {
+	checks []namedCheck
+}
+
+// Add registers a readiness check under name.
+func (h *Health) Add(name string, c Check) {
+	h.checks = append(h.checks, namedCheck{name: name, check: c})
+}
+
+// Register adds /healthz and /readyz to mux.
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.handleHealthz)
+	mux.HandleFunc("GET /readyz", h.handleReadyz)
+}
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// handleHealthz answers as long as the process can serve requests.
+func (h *Health) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, healthResponse{Status: "ok"})
+}
+
+// handleReadyz runs every check, failing with 503 if any fails.
+func (h *Health) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	resp := healthResponse{Status: "ok", Checks: make(map[string]string, len(h.checks))}
+	for _, c := range h.checks {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := c.check(ctx)
+		cancel()
+		if err != nil {
+			resp.Status = "fail"
+			resp.Checks[c.name] = err.Error()
+		} else {
+			resp.Checks[c.name] = "ok"
+		}
+	}
+
+	if resp.Status != "ok" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, resp)
+}
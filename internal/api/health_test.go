@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	a, _ := newTestAPI(t)
+	ok := func(context.Context) error { return nil }
+	stale := func(context.Context) error { return errors.New("no message from reflector for 5m0s") }
+	wedged := func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }
+
+	tests := []struct {
+		name   string
+		path   string
+		checks map[string]Check
+		status int
+		want   map[string]string
+	}{
+		{
+			name:   "Alive Despite Failing Checks",
+			path:   "/healthz",
+			checks: map[string]Check{"nng": stale},
+			status: 200,
+		},
+		{
+			name:   "Ready",
+			path:   "/readyz",
+			checks: map[string]Check{"nng": ok, "db": a.Store.Ping},
+			status: 200,
+			want:   map[string]string{"nng": "ok", "db": "ok"},
+		},
+		{
+			name:   "Stale Feed",
+			path:   "/readyz",
+			checks: map[string]Check{"nng": stale, "db": ok},
+			status: 503,
+			want:   map[string]string{"nng": "no message from reflector for 5m0s", "db": "ok"},
+		},
+		{
+			name:   "Wedged",
+			path:   "/readyz",
+			checks: map[string]Check{"hub": wedged},
+			status: 503,
+			want:   map[string]string{"hub": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Health{}
+			for name, c := range tt.checks {
+				h.Add(name, c)
+			}
+			mux := http.NewServeMux()
+			h.Register(mux)
+
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			var resp healthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := resp.Checks[name]; got != want {
+					t.Errorf("Expected %s check %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 1000
+)
+
+// handleHistory lists hearings newest first. Pages are requested with
+// before_id set to the smallest id of the previous page; X-Total-Count
+// reports the number of rows matching the filters across all pages.
+func (a *API) handleHistory(w http.ResponseWriter, r *http.Request) {
+	f, err := parseHearingFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	total, err := a.Hearings.CountHearings(f)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	hearings, err := a.Hearings.ListHearings(f)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	writeJSON(w, hearings)
+}
+
+// parseHearingFilter reads reflector, my, module, protocol, since, until,
+// min_duration, before_id and limit from the query string.
+func parseHearingFilter(q url.Values) (store.HearingFilter, error) {
+	f := store.HearingFilter{
+		Reflector: q.Get("reflector"),
+		My:        q.Get("my"),
+		Module:    q.Get("module"),
+		Protocol:  q.Get("protocol"),
+		Limit:     defaultHistoryLimit,
+	}
+
+	var err error
+	if f.Since, err = parseTime(q, "since"); err != nil {
+		return f, err
+	}
+	if f.Until, err = parseTime(q, "until"); err != nil {
+		return f, err
+	}
+	if v := q.Get("min_duration"); v != "" {
+		if f.MinDuration, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid min_duration: %q", v)
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return f, fmt.Errorf("invalid before_id: %q", v)
+		}
+		f.BeforeID = uint(id)
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			return f, fmt.Errorf("invalid limit: %q", v)
+		}
+		f.Limit = min(f.Limit, maxHistoryLimit)
+	}
+	return f, nil
+}
+
+// parseTime accepts RFC 3339 timestamps or Unix seconds.
+func parseTime(q url.Values, key string) (time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s: %q", key, v)
+}
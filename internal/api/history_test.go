@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func newTestAPI(t *testing.T) (*API, *http.ServeMux) {
+	t.Helper()
+	s, err := store.NewStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	a := New(s)
+	mux := http.NewServeMux()
+	a.Register(mux)
+	return a, mux
+}
+
+// newMemoryAPI returns an API whose hearings are held in memory.
+func newMemoryAPI(t *testing.T) (*API, *http.ServeMux) {
+	t.Helper()
+	a := &API{Hearings: store.NewMemory()}
+	mux := http.NewServeMux()
+	a.Register(mux)
+	return a, mux
+}
+
+func TestHistory(t *testing.T) {
+	backends := map[string]func(*testing.T) (*API, *http.ServeMux){
+		"sqlite": newTestAPI,
+		"memory": newMemoryAPI,
+	}
+	for name, newAPI := range backends {
+		t.Run(name, func(t *testing.T) {
+			a, mux := newAPI(t)
+			testHistory(t, a, mux)
+		})
+	}
+}
+
+func testHistory(t *testing.T, a *API, mux *http.ServeMux) {
+	for _, call := range []string{"W8CPT", "KF8S", "W8EAP", "W8FU"} {
+		if err := a.Hearings.CreateHearing(&store.Hearing{My: call, Module: "A"}); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		total  string
+		want   []string
+	}{
+		{name: "Default", query: "", status: 200, total: "4", want: []string{"W8FU", "W8EAP", "KF8S", "W8CPT"}},
+		{name: "Page", query: "?my=W8*&limit=2", status: 200, total: "3", want: []string{"W8FU", "W8EAP"}},
+		{name: "Next Page", query: "?my=W8*&limit=2&before_id=3", status: 200, total: "3", want: []string{"W8CPT"}},
+		{name: "Empty", query: "?module=Z", status: 200, total: "0", want: []string{}},
+		{name: "Bad Limit", query: "?limit=0", status: 400},
+		{name: "Bad Time", query: "?since=yesterday", status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/history"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+			if got := rec.Header().Get("X-Total-Count"); got != tt.total {
+				t.Errorf("Expected X-Total-Count %s, got %s", tt.total, got)
+			}
+
+			var hearings []store.Hearing
+			if err := json.Unmarshal(rec.Body.Bytes(), &hearings); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if len(hearings) != len(tt.want) {
+				t.Fatalf("Expected %d hearings, got %d", len(tt.want), len(hearings))
+			}
+			for i, h := range hearings {
+				if h.My != tt.want[i] {
+					t.Errorf("Expected %s at %d, got %s", tt.want[i], i, h.My)
+				}
+			}
+		})
+	}
+}
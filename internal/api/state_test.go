@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+)
+
+func TestState(t *testing.T) {
+	a := &API{}
+	mux := http.NewServeMux()
+	a.Register(mux)
+	a.States = func() []nng.Event {
+		return []nng.Event{
+			{Type: "state", Reflector: "URF001", Seq: 4, Peers: []nng.Peer{{Callsign: "XLX262"}}},
+			{Reflector: "URF002"},
+			{Type: "state", Reflector: "URF003", Seq: 9},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		// list is set for endpoints returning every snapshot
+		list bool
+		want []string
+	}{
+		{name: "First Reflector", path: "/api/state", status: 200, want: []string{"URF001"}},
+		{name: "By Reflector", path: "/api/state?reflector=URF003", status: 200, want: []string{"URF003"}},
+		{name: "No State Yet", path: "/api/state?reflector=URF002", status: 503},
+		{name: "Unknown Reflector", path: "/api/state?reflector=XLX000", status: 503},
+		{name: "All", path: "/api/states", status: 200, list: true, want: []string{"URF001", "URF003"}},
+		{name: "All Filtered", path: "/api/states?reflector=URF002", status: 200, list: true, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rec.Code, rec.Body)
+			}
+			if tt.status != 200 {
+				return
+			}
+
+			var states []nng.Event
+			if !tt.list {
+				var ev nng.Event
+				if err := json.Unmarshal(rec.Body.Bytes(), &ev); err != nil {
+					t.Fatalf("Unmarshal failed: %v", err)
+				}
+				states = append(states, ev)
+			} else if err := json.Unmarshal(rec.Body.Bytes(), &states); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+
+			if len(states) != len(tt.want) {
+				t.Fatalf("Expected %v, got %+v", tt.want, states)
+			}
+			for i, ev := range states {
+				if ev.Reflector != tt.want[i] {
+					t.Errorf("Expected %v, got %+v", tt.want, states)
+				}
+			}
+		})
+	}
+}
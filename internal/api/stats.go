@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// defaultStatsRange is how far back /api/stats/modules looks when no since
+// parameter is given.
+var defaultStatsRange = map[store.Bucket]time.Duration{
+	store.BucketHour: 24 * time.Hour,
+	store.BucketDay:  30 * 24 * time.Hour,
+	store.BucketWeek: 12 * 7 * 24 * time.Hour,
+}
+
+// ModuleStats is the activity of one module along with its configured
+// description. Configured modules are listed even when idle.
+type ModuleStats struct {
+	store.ModuleActivity
+	Description string `json:"description,omitempty"`
+	Configured  bool   `json:"configured"`
+}
+
+type moduleStatsResponse struct {
+	Bucket  store.Bucket  `json:"bucket"`
+	Since   time.Time     `json:"since"`
+	Until   time.Time     `json:"until"`
+	Modules []ModuleStats `json:"modules"`
+}
+
+// handleModuleStats reports transmissions, unique callsigns and airtime per
+// module and per hour, day or week bucket.
+func (a *API) handleModuleStats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	bucket := store.BucketDay
+	if v := q.Get("bucket"); v != "" {
+		var err error
+		if bucket, err = store.ParseBucket(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	f, err := parseHearingFilter(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Until.IsZero() {
+		f.Until = time.Now().UTC()
+	}
+	if f.Since.IsZero() {
+		f.Since = bucket.Start(f.Until.Add(-defaultStatsRange[bucket]))
+	}
+
+	activity, err := a.Hearings.ModuleActivity(f, bucket)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	var modules map[string]string
+	if a.Modules != nil {
+		modules = a.Modules(f.Reflector)
+	}
+	stats := make([]ModuleStats, 0, len(activity))
+	seen := make(map[string]bool)
+	for _, m := range activity {
+		desc, ok := modules[m.Module]
+		stats = append(stats, ModuleStats{ModuleActivity: m, Description: desc, Configured: ok})
+		seen[m.Module] = true
+	}
+	for name, desc := range modules {
+		if !seen[name] && (f.Module == "" || f.Module == name) {
+			stats = append(stats, ModuleStats{
+				ModuleActivity: store.ModuleActivity{Module: name, Buckets: []store.BucketActivity{}},
+				Description:    desc,
+				Configured:     true,
+			})
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Module < stats[j].Module })
+
+	writeJSON(w, moduleStatsResponse{
+		Bucket:  bucket,
+		Since:   f.Since.UTC(),
+		Until:   f.Until.UTC(),
+		Modules: stats,
+	})
+}
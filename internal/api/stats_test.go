@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func TestModuleStats(t *testing.T) {
+	a, mux := newTestAPI(t)
+	modules := map[string]map[string]string{
+		"":       {"A": "World Wide", "D": "Links"},
+		"URF002": {"C": "Local"},
+	}
+	a.Modules = func(reflector string) map[string]string { return modules[reflector] }
+
+	base := time.Date(2025, 12, 27, 9, 0, 0, 0, time.UTC)
+	for _, h := range []store.Hearing{
+		{My: "KF8S", Module: "A", Duration: 10, CreatedAt: base},
+		{My: "KF8S", Module: "A", Duration: 5, CreatedAt: base.Add(10 * time.Minute)},
+		{My: "W8CPT", Module: "A", Duration: 20, CreatedAt: base.Add(2 * time.Hour)},
+		{My: "N7TAE", Module: "B", Duration: 4, CreatedAt: base.Add(30 * time.Minute)},
+		{My: "N7TAE", Module: "B", Duration: 4, CreatedAt: base.Add(-48 * time.Hour)},
+	} {
+		if err := a.Store.DB.Create(&h).Error; err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stats/modules?bucket=hour&since=2025-12-27T00:00:00Z&until=2025-12-28T00:00:00Z", nil))
+	if rec.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp moduleStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if resp.Bucket != store.BucketHour || len(resp.Modules) != 3 {
+		t.Fatalf("Unexpected response: %+v", resp)
+	}
+
+	modA := resp.Modules[0]
+	if modA.Module != "A" || !modA.Configured || modA.Description != "World Wide" {
+		t.Errorf("Unexpected module A: %+v", modA)
+	}
+	if modA.Transmissions != 3 || modA.UniqueCallsigns != 2 || modA.Airtime != 35 {
+		t.Errorf("Unexpected module A totals: %+v", modA.Activity)
+	}
+	if len(modA.Buckets) != 2 || !modA.Buckets[0].Start.Equal(base) || modA.Buckets[0].Transmissions != 2 || modA.Buckets[0].UniqueCallsigns != 1 {
+		t.Errorf("Unexpected module A buckets: %+v", modA.Buckets)
+	}
+
+	modB := resp.Modules[1]
+	if modB.Module != "B" || modB.Configured || modB.Transmissions != 1 {
+		t.Errorf("Unexpected module B: %+v", modB)
+	}
+
+	modD := resp.Modules[2]
+	if modD.Module != "D" || !modD.Configured || modD.Transmissions != 0 {
+		t.Errorf("Unexpected idle module D: %+v", modD)
+	}
+
+	// The reflector filter selects that reflector's configured modules
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stats/modules?reflector=URF002", nil))
+	resp = moduleStatsResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(resp.Modules) != 1 || resp.Modules[0].Module != "C" || resp.Modules[0].Description != "Local" {
+		t.Errorf("Expected only URF002's module C, got %+v", resp.Modules)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stats/modules?bucket=month", nil))
+	if rec.Code != 400 {
+		t.Errorf("Expected status 400 for bad bucket, got %d", rec.Code)
+	}
+}
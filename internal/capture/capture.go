@@ -0,0 +1,132 @@
+// Package capture records the raw messages received from reflectors to
+// NDJSON files and reads them back for replay.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// maxRecord bounds the length of a line read back from a capture.
+const maxRecord = 4 << 20
+
+// Record is one captured message, written as a line of NDJSON.
+type Record struct {
+	// Received is when the dashboard received the message.
+	Received time.Time `json:"received"`
+	// Reflector is the ID of the reflector that sent it.
+	Reflector string `json:"reflector,omitempty"`
+	// Message is the message as published by the reflector.
+	Message json.RawMessage `json:"message,omitempty"`
+	// Data holds a message that is not valid JSON instead, base64 encoded,
+	// so malformed messages are kept byte for byte.
+	Data []byte `json:"data,omitempty"`
+}
+
+// NewRecord records msg, received from reflector at received.
+func NewRecord(received time.Time, reflector string, msg []byte) Record {
+	rec := Record{Received: received, Reflector: reflector}
+	if json.Valid(msg) {
+		rec.Message = json.RawMessage(msg)
+	} else {
+		rec.Data = msg
+	}
+	return rec
+}
+
+// Bytes returns the captured message.
+func (r Record) Bytes() []byte {
+	if r.Data != nil {
+		return r.Data
+	}
+	return r.Message
+}
+
+// Config describes a capture file, rotated like the log file.
+type Config struct {
+	FilePath   string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// Writer appends records to a rotating capture file. It is safe for
+// concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+	enc *json.Encoder
+}
+
+// NewWriter opens the capture file described by cfg, creating its
+// directory if needed.
+func NewWriter(cfg Config) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+		return nil, err
+	}
+	out := &lumberjack.Logger{
+		Filename:   cfg.FilePath,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}
+	return newWriter(out), nil
+}
+
+func newWriter(out io.WriteCloser) *Writer {
+	return &Writer{out: out, enc: json.NewEncoder(out)}
+}
+
+// Write appends rec as one line.
+func (w *Writer) Write(rec Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(rec)
+}
+
+// Close closes the capture file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Close()
+}
+
+// Reader reads records from a capture.
+type Reader struct {
+	sc *bufio.Scanner
+}
+
+func NewReader(r io.Reader) *Reader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxRecord)
+	return &Reader{sc: sc}
+}
+
+// Next returns the next record, or io.EOF at the end of the capture. Blank
+// lines are skipped.
+func (r *Reader) Next() (Record, error) {
+	for r.sc.Scan() {
+		line := r.sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return rec, err
+		}
+		return rec, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
@@ -0,0 +1,155 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture", "nng.ndjson")
+	w, err := NewWriter(Config{FilePath: path, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	received := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	want := []Record{
+		{Received: received, Reflector: "URF270", Message: json.RawMessage(`{"type":"hearing","my":"KF8S"}`)},
+		{Received: received.Add(time.Second), Message: json.RawMessage(`{"type":"closing","my":"KF8S"}`)},
+		// A truncated message is kept as is
+		NewRecord(received.Add(2*time.Second), "URF270", []byte(`{"type": "hear`)),
+	}
+	for _, rec := range want {
+		if err := w.Write(rec); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open capture: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	r := NewReader(f)
+	for _, w := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if !got.Received.Equal(w.Received) || got.Reflector != w.Reflector || string(got.Bytes()) != string(w.Bytes()) {
+			t.Errorf("Expected %+v, got %+v", w, got)
+		}
+	}
+	if _, err := r.Next(); err == nil {
+		t.Errorf("Expected EOF after the last record")
+	}
+}
+
+// capture returns a reader over records received one second apart.
+func capture(reflectors ...string) *Reader {
+	start := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	var b strings.Builder
+	w := newWriter(nopCloser{&b})
+	for i, id := range reflectors {
+		_ = w.Write(Record{
+			Received:  start.Add(time.Duration(i) * time.Second),
+			Reflector: id,
+			Message:   json.RawMessage(`{"type":"state"}`),
+		})
+	}
+	return NewReader(strings.NewReader(b.String()))
+}
+
+type nopCloser struct{ *strings.Builder }
+
+func (nopCloser) Close() error { return nil }
+
+func TestReplay(t *testing.T) {
+	stop := errors.New("stop")
+	tests := []struct {
+		name    string
+		opts    ReplayOptions
+		want    int
+		wantErr error
+		// minimum wall time of the replay
+		atLeast time.Duration
+	}{
+		{name: "As Fast As Possible", opts: ReplayOptions{}, want: 3},
+		{name: "Reflector", opts: ReplayOptions{Reflector: "URF271"}, want: 1},
+		{name: "Accelerated", opts: ReplayOptions{Speed: 50}, want: 3, atLeast: 40 * time.Millisecond},
+		{
+			name: "Stepwise",
+			opts: ReplayOptions{Speed: 1, Step: func() func(Record) error {
+				n := 0
+				return func(Record) error {
+					if n++; n > 2 {
+						return stop
+					}
+					return nil
+				}
+			}()},
+			want:    2,
+			wantErr: stop,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			start := time.Now()
+			n, err := Replay(context.Background(), capture("URF270", "URF271", "URF270"), func(msg []byte) error {
+				sent = append(sent, string(msg))
+				return nil
+			}, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if n != tt.want || len(sent) != tt.want {
+				t.Errorf("Expected %d published, got %d (%d sent)", tt.want, n, len(sent))
+			}
+			if elapsed := time.Since(start); elapsed < tt.atLeast {
+				t.Errorf("Expected the replay to take at least %v, took %v", tt.atLeast, elapsed)
+			}
+		})
+	}
+}
+
+func TestReplayCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := Replay(ctx, capture("URF270", "URF270"), func([]byte) error {
+		cancel()
+		return nil
+	}, ReplayOptions{Speed: 1})
+	if !errors.Is(err, context.Canceled) || n != 1 {
+		t.Errorf("Expected to stop after 1 message with context.Canceled, got %d and %v", n, err)
+	}
+}
+
+func TestGap(t *testing.T) {
+	t0 := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		next  time.Time
+		speed float64
+		want  time.Duration
+	}{
+		{name: "Original", next: t0.Add(2 * time.Second), speed: 1, want: 2 * time.Second},
+		{name: "Accelerated", next: t0.Add(2 * time.Second), speed: 4, want: 500 * time.Millisecond},
+		{name: "Out of Order", next: t0.Add(-time.Second), speed: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gap(t0, tt.next, tt.speed); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
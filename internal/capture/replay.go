@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ReplayOptions control the pace of Replay.
+type ReplayOptions struct {
+	// Speed scales the gaps between records: 1 keeps the original timing,
+	// 10 replays ten times faster. Zero or less sends without waiting.
+	Speed float64
+	// Step, if set, is called before each record and paces the replay
+	// instead of Speed, e.g. by waiting for a key press. Returning an error
+	// stops the replay.
+	Step func(Record) error
+	// Reflector, if set, replays only that reflector's records.
+	Reflector string
+}
+
+// Replay publishes the message of every record from r until the capture
+// ends or ctx is cancelled, and returns the number published.
+func Replay(ctx context.Context, r *Reader, publish func([]byte) error, opts ReplayOptions) (int, error) {
+	var prev time.Time
+	sent := 0
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+		if opts.Reflector != "" && rec.Reflector != opts.Reflector {
+			continue
+		}
+
+		if opts.Step != nil {
+			if err := opts.Step(rec); err != nil {
+				return sent, err
+			}
+		} else if opts.Speed > 0 && !prev.IsZero() {
+			if err := sleep(ctx, gap(prev, rec.Received, opts.Speed)); err != nil {
+				return sent, err
+			}
+		}
+		prev = rec.Received
+
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		if err := publish(rec.Bytes()); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+}
+
+// gap is the time to wait between records received at prev and next.
+// Records out of order are sent immediately.
+func gap(prev, next time.Time, speed float64) time.Duration {
+	d := next.Sub(prev)
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(float64(d) / speed)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
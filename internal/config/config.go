@@ -1,24 +1,69 @@
 package config
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	Server    ServerConfig    `mapstructure:"server" json:"server"`
+	Database  DatabaseConfig  `mapstructure:"database" json:"-"`
 	Reflector ReflectorConfig `mapstructure:"reflector" json:"reflector"`
-	Logging   LoggingConfig   `mapstructure:"logging" json:"logging"`
+	// Reflectors are the reflectors to subscribe to. Without a list, the
+	// single reflector described by reflector and server.nng_url is used.
+	Reflectors []ReflectorConfig `mapstructure:"reflectors" json:"reflectors"`
+	Logging    LoggingConfig     `mapstructure:"logging" json:"logging"`
+	Capture    CaptureConfig     `mapstructure:"capture" json:"-"`
 }
 
 type ServerConfig struct {
 	Addr   string `mapstructure:"addr" json:"addr"`
 	NNGURL string `mapstructure:"nng_url" json:"nng_url"`
+	// DBPath is the SQLite database used when database.dsn is not set.
 	DBPath string `mapstructure:"db_path" json:"db_path"`
+	// NNGStaleTimeout is how long the reflector may go without sending a
+	// state event before the link is considered stale and redialed.
+	NNGStaleTimeout time.Duration `mapstructure:"nng_stale_timeout" json:"nng_stale_timeout"`
+	// ReadyWindow is how long the reflector may go without sending any
+	// message before /readyz fails.
+	ReadyWindow time.Duration   `mapstructure:"ready_window" json:"ready_window"`
+	Retention   RetentionConfig `mapstructure:"retention" json:"retention"`
+}
+
+// RetentionConfig bounds the history kept in the database. With neither
+// MaxAge nor MaxRows set, history is kept forever.
+type RetentionConfig struct {
+	MaxAge  time.Duration `mapstructure:"max_age" json:"max_age"`
+	MaxRows int           `mapstructure:"max_rows" json:"max_rows"`
+	// Downsample keeps daily per-callsign, per-module totals of pruned
+	// hearings.
+	Downsample      bool          `mapstructure:"downsample" json:"downsample"`
+	Interval        time.Duration `mapstructure:"interval" json:"interval"`
+	CompactInterval time.Duration `mapstructure:"compact_interval" json:"compact_interval"`
+}
+
+// DatabaseConfig selects the history database.
+type DatabaseConfig struct {
+	// Driver is "sqlite" (the default) or "postgres".
+	Driver string `mapstructure:"driver"`
+	// DSN is the SQLite file path, defaulting to server.db_path, or the
+	// PostgreSQL connection string.
+	DSN string `mapstructure:"dsn"`
+	// WriteInterval is how often queued hearing writes are flushed in a
+	// batch; 0 writes each one synchronously. WriteBatch flushes early once
+	// that many writes are queued.
+	WriteInterval time.Duration `mapstructure:"write_interval"`
+	WriteBatch    int           `mapstructure:"write_batch"`
 }
 
 type ReflectorConfig struct {
+	// ID tags the events, hearings and connections of the reflector. It may
+	// be empty for a single reflector.
+	ID          string            `mapstructure:"id" json:"id"`
+	NNGURL      string            `mapstructure:"nng_url" json:"-"`
 	Name        string            `mapstructure:"name" json:"name"`
 	Description string            `mapstructure:"description" json:"description"`
 	Modules     map[string]string `mapstructure:"modules" json:"modules"`
@@ -34,6 +79,17 @@ type LoggingConfig struct {
 	Console    bool   `mapstructure:"console"`
 }
 
+// CaptureConfig records every message received from the reflectors to a
+// rotating NDJSON file for replay with the simulator. Capture is off unless
+// FilePath is set.
+type CaptureConfig struct {
+	FilePath   string `mapstructure:"file_path"`
+	MaxSizeMB  int    `mapstructure:"max_size_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+	Compress   bool   `mapstructure:"compress"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	v := viper.New()
 
@@ -41,6 +97,15 @@ func LoadConfig(path string) (*Config, error) {
 	v.SetDefault("server.addr", ":8080")
 	v.SetDefault("server.nng_url", "tcp://127.0.0.1:5555")
 	v.SetDefault("server.db_path", "data/dashboard.db")
+	v.SetDefault("server.nng_stale_timeout", "30s")
+	v.SetDefault("server.ready_window", "2m")
+	v.SetDefault("database.driver", "sqlite")
+	// Without a default AutomaticEnv would ignore URFD_DATABASE_DSN
+	v.SetDefault("database.dsn", "")
+	v.SetDefault("database.write_interval", "250ms")
+	v.SetDefault("database.write_batch", 100)
+	v.SetDefault("server.retention.interval", "1h")
+	v.SetDefault("server.retention.compact_interval", "24h")
 	v.SetDefault("reflector.name", "URFD Dashboard")
 	v.SetDefault("reflector.description", "Universal Reflector Dashboard")
 	v.SetDefault("logging.level", "info")
@@ -66,6 +131,33 @@ func LoadConfig(path string) (*Config, error) {
 	if err := v.Unmarshal(&c); err != nil {
 		return nil, err
 	}
+	if c.Database.DSN == "" && c.Database.Driver == "sqlite" {
+		c.Database.DSN = c.Server.DBPath
+	}
+	if len(c.Reflectors) == 0 {
+		r := c.Reflector
+		r.NNGURL = c.Server.NNGURL
+		c.Reflectors = []ReflectorConfig{r}
+	}
+	if err := c.validateReflectors(); err != nil {
+		return nil, err
+	}
 
 	return &c, nil
 }
+
+// validateReflectors checks that every reflector has an NNG URL and that
+// IDs are unique.
+func (c *Config) validateReflectors() error {
+	seen := make(map[string]bool, len(c.Reflectors))
+	for i, r := range c.Reflectors {
+		if r.NNGURL == "" {
+			return fmt.Errorf("reflectors[%d]: nng_url is required", i)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("reflectors[%d]: duplicate id %q", i, r.ID)
+		}
+		seen[r.ID] = true
+	}
+	return nil
+}
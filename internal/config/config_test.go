@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigReflectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr bool
+		want    []ReflectorConfig
+	}{
+		{
+			name: "Single Reflector",
+			yaml: "server:\n  nng_url: tcp://urfd:5555\nreflector:\n  name: URF270\n",
+			want: []ReflectorConfig{{NNGURL: "tcp://urfd:5555", Name: "URF270"}},
+		},
+		{
+			name: "Reflector List",
+			yaml: "reflectors:\n" +
+				"  - id: URF270\n    nng_url: tcp://host1:5555\n" +
+				"  - id: URF271\n    nng_url: tcp://host2:5555\n    name: Backup\n",
+			want: []ReflectorConfig{
+				{ID: "URF270", NNGURL: "tcp://host1:5555"},
+				{ID: "URF271", NNGURL: "tcp://host2:5555", Name: "Backup"},
+			},
+		},
+		{
+			name:    "Duplicate ID",
+			yaml:    "reflectors:\n  - id: URF270\n    nng_url: tcp://a:1\n  - id: URF270\n    nng_url: tcp://b:1\n",
+			wantErr: true,
+		},
+		{
+			name:    "Missing URL",
+			yaml:    "reflectors:\n  - id: URF270\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			cfg, err := LoadConfig(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if len(cfg.Reflectors) != len(tt.want) {
+				t.Fatalf("Expected %d reflectors, got %+v", len(tt.want), cfg.Reflectors)
+			}
+			for i, want := range tt.want {
+				got := cfg.Reflectors[i]
+				if got.ID != want.ID || got.NNGURL != want.NNGURL || got.Name != want.Name {
+					t.Errorf("Expected reflector %+v, got %+v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigDatabaseEnv(t *testing.T) {
+	dsn := "postgres://urfd:secret@db:5432/urfd?sslmode=disable"
+	t.Setenv("URFD_DATABASE_DRIVER", "postgres")
+	t.Setenv("URFD_DATABASE_DSN", dsn)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  addr: \":8080\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Database.Driver != "postgres" || cfg.Database.DSN != dsn {
+		t.Errorf("Expected postgres at %s, got %s at %q", dsn, cfg.Database.Driver, cfg.Database.DSN)
+	}
+}
@@ -0,0 +1,93 @@
+// Package metrics defines the dashboard's Prometheus metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "urfd_dashboard"
+
+// Registry holds the dashboard's metrics along with the Go runtime and
+// process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// NNGMessages counts messages received from the reflector by type, with
+	// unknown types counted as "other".
+	NNGMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "nng",
+		Name:      "messages_received_total",
+		Help:      "Messages received from the reflector, by type.",
+	}, []string{"type"})
+
+	// NNGDecodeErrors counts messages that were not valid JSON.
+	NNGDecodeErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "nng",
+		Name:      "decode_errors_total",
+		Help:      "Messages from the reflector that could not be decoded.",
+	})
+
+	// DBWriteSeconds measures hearing inserts and updates by operation:
+	// "create", "update_module" or "update_duration" when hearings are
+	// written synchronously, and "batch" for each transaction of queued
+	// writes.
+	DBWriteSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "hearing_write_seconds",
+		Help:      "Latency of hearing inserts and updates.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+
+	// Linked is the number of clients, users and peers in the last state
+	// snapshot of each reflector, by kind and module. Peers have no module.
+	Linked = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "reflector",
+		Name:      "linked",
+		Help:      "Linked clients, users and peers in the last state, by reflector and module.",
+	}, []string{"reflector", "kind", "module"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		NNGMessages,
+		NNGDecodeErrors,
+		DBWriteSeconds,
+		Linked,
+	)
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+func GaugeFunc(subsystem, name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// CounterFunc registers a counter whose value is read from fn at scrape
+// time.
+func CounterFunc(subsystem, name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -1,25 +1,33 @@
 package nng
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.nanomsg.org/mangos/v3"
 	"go.nanomsg.org/mangos/v3/protocol/sub"
 	_ "go.nanomsg.org/mangos/v3/transport/all"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/metrics"
 )
 
 // Event represents the common structure of NNG messages from urfd
 type Event struct {
 	ID        uint      `json:"id,omitempty"`
 	Type      string    `json:"type"`
-	Status    string    `json:"status,omitempty"` // "active" | "ended"
+	Status    string    `json:"status,omitempty"` // "active" | "ended", or a Status for "connection"
 	Duration  float64   `json:"duration,omitempty"`
 	CreatedAt time.Time `json:"created_at,omitempty"`
 	Callsign  string    `json:"callsign,omitempty"` // for client_connect/disconnect
 	Module    string    `json:"module,omitempty"`
 	Protocol  string    `json:"protocol,omitempty"`
+	// Reflector is set by the dashboard to the ID of the reflector the
+	// event came from.
+	Reflector string `json:"reflector,omitempty"`
 
 	// Hearing fields
 	My   string `json:"my,omitempty"`
@@ -33,8 +41,11 @@ type Event struct {
 	Users         []User         `json:"Users,omitempty"`
 	Peers         []Peer         `json:"Peers,omitempty"`
 	Modules       []Module       `json:"Modules,omitempty"`
-
-	Raw json.RawMessage `json:"-"`
+	// Configure is the reflector's configuration, passed through as is.
+	Configure json.RawMessage `json:"Configure,omitempty"`
+	// Seq is set by the dashboard on state snapshots sent to clients: the
+	// sequence number of the last state_diff the snapshot includes.
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 type Module struct {
@@ -69,48 +80,199 @@ type Peer struct {
 	ConnectTime time.Time `json:"ConnectTime"`
 }
 
-// Subscriber listens for NNG events
+// Status describes the health of the link to the reflector
+type Status string
+
+const (
+	// StatusConnecting is reported while dialing, and after a successful
+	// dial until the first message arrives.
+	StatusConnecting Status = "connecting"
+	// StatusConnected is reported while messages are flowing.
+	StatusConnected Status = "connected"
+	// StatusStale is reported when no state event arrived within the stale
+	// timeout. The socket is torn down and redialed.
+	StatusStale Status = "stale"
+)
+
+const (
+	DefaultStaleTimeout = 30 * time.Second
+	DefaultMinBackoff   = 1 * time.Second
+	DefaultMaxBackoff   = 60 * time.Second
+
+	// recvPoll bounds how long Recv blocks so staleness is noticed even
+	// when nothing arrives at all.
+	recvPoll = time.Second
+)
+
+// Subscriber listens for NNG events. It dials in the background, redialing
+// with exponential backoff, and treats a reflector that stops sending state
+// events as disconnected.
 type Subscriber struct {
-	url  string
-	sock mangos.Socket
+	// StaleTimeout is how long to wait for a state event before redialing.
+	// Zero disables stale detection.
+	StaleTimeout time.Duration
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	// OnStatus is called whenever the connection status changes.
+	OnStatus func(Status)
+	// OnMessage is called with every message received, before it is
+	// decoded, so messages that fail to decode are seen too.
+	OnMessage func([]byte)
+	// Reflector is copied into every event received.
+	Reflector string
+
+	url    string
+	mu     sync.RWMutex
+	status Status
+	// lastMessage is when the last message arrived, in Unix nanoseconds.
+	lastMessage atomic.Int64
 }
 
-func NewSubscriber(url string) (*Subscriber, error) {
-	sock, err := sub.NewSocket()
-	if err != nil {
-		return nil, err
+func NewSubscriber(url string) *Subscriber {
+	return &Subscriber{
+		StaleTimeout: DefaultStaleTimeout,
+		MinBackoff:   DefaultMinBackoff,
+		MaxBackoff:   DefaultMaxBackoff,
+		url:          url,
+		status:       StatusConnecting,
+	}
+}
+
+// Status returns the current connection status.
+func (s *Subscriber) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// LastMessage returns when the last message of any type arrived, or the
+// zero time if none has.
+func (s *Subscriber) LastMessage() time.Time {
+	if ns := s.lastMessage.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+func (s *Subscriber) setStatus(st Status) {
+	s.mu.Lock()
+	changed := s.status != st
+	s.status = st
+	s.mu.Unlock()
+
+	if changed {
+		log.Printf("NNG %s: %s", s.url, st)
+		if s.OnStatus != nil {
+			s.OnStatus(st)
+		}
 	}
+}
 
-	if err := sock.Dial(url); err != nil {
+func (s *Subscriber) dial() (mangos.Socket, error) {
+	sock, err := sub.NewSocket()
+	if err != nil {
 		return nil, err
 	}
 
 	// Subscribe to all topics (empty prefix)
 	if err := sock.SetOption(mangos.OptionSubscribe, []byte("")); err != nil {
+		_ = sock.Close()
+		return nil, err
+	}
+	if err := sock.SetOption(mangos.OptionRecvDeadline, recvPoll); err != nil {
+		_ = sock.Close()
 		return nil, err
 	}
 
-	return &Subscriber{
-		url:  url,
-		sock: sock,
-	}, nil
+	if err := sock.Dial(s.url); err != nil {
+		_ = sock.Close()
+		return nil, err
+	}
+	return sock, nil
 }
 
-func (s *Subscriber) Listen(callback func(Event)) error {
-	for {
-		msg, err := s.sock.Recv()
+// Listen dials the reflector and delivers events to callback until ctx is
+// cancelled. Dial failures and stale links are retried with backoff. No
+// callback is running once Listen returns.
+func (s *Subscriber) Listen(ctx context.Context, callback func(Event)) error {
+	backoff := s.MinBackoff
+	for ctx.Err() == nil {
+		s.setStatus(StatusConnecting)
+		sock, err := s.dial()
 		if err != nil {
-			log.Printf("NNG Recv error: %v", err)
+			log.Printf("NNG dial %s failed: %v (retrying in %v)", s.url, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+			backoff = min(backoff*2, s.MaxBackoff)
 			continue
 		}
+		backoff = s.MinBackoff
 
-		var event Event
-		if err := json.Unmarshal(msg, &event); err != nil {
-			log.Printf("JSON Unmarshal error: %v", err)
-			continue
+		s.receive(ctx, sock, callback)
+		_ = sock.Close()
+	}
+	return nil
+}
+
+// receive reads from sock until ctx is cancelled, the link goes stale or
+// the socket fails.
+func (s *Subscriber) receive(ctx context.Context, sock mangos.Socket, callback func(Event)) {
+	lastState := time.Now()
+	for ctx.Err() == nil {
+		if msg, err := sock.Recv(); err == nil {
+			s.lastMessage.Store(time.Now().UnixNano())
+			s.setStatus(StatusConnected)
+			if s.OnMessage != nil {
+				s.OnMessage(msg)
+			}
+			if event, ok := decode(msg); ok {
+				event.Reflector = s.Reflector
+				if event.Type == "state" {
+					lastState = time.Now()
+				}
+				callback(event)
+			}
+		} else if err != mangos.ErrRecvTimeout {
+			log.Printf("NNG Recv error: %v", err)
+			if err == mangos.ErrClosed {
+				return
+			}
+		}
+
+		if s.StaleTimeout > 0 && time.Since(lastState) > s.StaleTimeout {
+			s.setStatus(StatusStale)
+			return
 		}
+	}
+}
+
+func decode(msg []byte) (Event, bool) {
+	var event Event
+	if err := json.Unmarshal(msg, &event); err != nil {
+		log.Printf("JSON Unmarshal error: %v", err)
+		metrics.NNGDecodeErrors.Inc()
+		return event, false
+	}
+	metrics.NNGMessages.WithLabelValues(messageType(event.Type)).Inc()
+	return event, true
+}
+
+// knownTypes are the message types the reflector sends.
+var knownTypes = map[string]bool{
+	"hearing":           true,
+	"closing":           true,
+	"state":             true,
+	"client_connect":    true,
+	"client_disconnect": true,
+}
 
-		event.Raw = msg
-		callback(event)
+// messageType is the metric label for a message type: anything unknown is
+// counted as "other", so a misbehaving sender cannot create new series.
+func messageType(t string) string {
+	if knownTypes[t] {
+		return t
 	}
+	return "other"
 }
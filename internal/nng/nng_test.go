@@ -1,8 +1,15 @@
 package nng
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.nanomsg.org/mangos/v3/protocol/pub"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/metrics"
 )
 
 func TestParseEvent(t *testing.T) {
@@ -63,3 +70,136 @@ func TestParseEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestSubscriberReconnect(t *testing.T) {
+	url := "inproc://test-subscriber-reconnect"
+
+	statuses := make(chan Status, 16)
+	events := make(chan Event, 16)
+	s := NewSubscriber(url)
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 50 * time.Millisecond
+	s.StaleTimeout = 1500 * time.Millisecond
+	s.OnStatus = func(st Status) { statuses <- st }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Listen(ctx, func(ev Event) { events <- ev }) }()
+
+	// Nothing is listening yet, so the subscriber keeps dialing
+	time.Sleep(100 * time.Millisecond)
+	if st := s.Status(); st != StatusConnecting {
+		t.Fatalf("Expected %s before publisher starts, got %s", StatusConnecting, st)
+	}
+
+	pubSock, err := pub.NewSocket()
+	if err != nil {
+		t.Fatalf("Failed to create pub socket: %v", err)
+	}
+	defer func() { _ = pubSock.Close() }()
+	if err := pubSock.Listen(url); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	// Publish until the subscriber has dialed and received a message
+	deadline := time.After(5 * time.Second)
+	for received := false; !received; {
+		if err := pubSock.Send([]byte(`{"type": "state"}`)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		select {
+		case ev := <-events:
+			received = ev.Type == "state"
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Timed out waiting for state event")
+		}
+	}
+	if st := s.Status(); st != StatusConnected {
+		t.Fatalf("Expected %s after receiving, got %s", StatusConnected, st)
+	}
+
+	// A silent reflector goes stale
+	for {
+		select {
+		case st := <-statuses:
+			if st == StatusStale {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("Timed out waiting for %s, status is %s", StatusStale, s.Status())
+		}
+	}
+}
+
+func TestSubscriberOnMessage(t *testing.T) {
+	url := "inproc://test-subscriber-on-message"
+	pubSock, err := pub.NewSocket()
+	if err != nil {
+		t.Fatalf("Failed to create pub socket: %v", err)
+	}
+	defer func() { _ = pubSock.Close() }()
+	if err := pubSock.Listen(url); err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	raw := make(chan string, 64)
+	events := make(chan Event, 64)
+	s := NewSubscriber(url)
+	s.MinBackoff = 10 * time.Millisecond
+	s.OnMessage = func(msg []byte) { raw <- string(msg) }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Listen(ctx, func(ev Event) { events <- ev }) }()
+
+	// Publish until the subscriber has dialed and received both messages
+	malformed := `{"type": "hear`
+	deadline := time.After(5 * time.Second)
+	for received := false; !received; {
+		_ = pubSock.Send([]byte(malformed))
+		_ = pubSock.Send([]byte(`{"type": "state"}`))
+		select {
+		case <-events:
+			received = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Timed out waiting for state event")
+		}
+	}
+	if msg := <-raw; msg != malformed {
+		t.Errorf("Expected the malformed message first, got %q", msg)
+	}
+	if msg := <-raw; msg != `{"type": "state"}` {
+		t.Errorf("Expected the state message, got %q", msg)
+	}
+}
+
+func TestDecodeMetrics(t *testing.T) {
+	hearings := testutil.ToFloat64(metrics.NNGMessages.WithLabelValues("hearing"))
+	others := testutil.ToFloat64(metrics.NNGMessages.WithLabelValues("other"))
+	errors := testutil.ToFloat64(metrics.NNGDecodeErrors)
+
+	if _, ok := decode([]byte(`{"type": "hearing", "my": "KF8S"}`)); !ok {
+		t.Fatalf("Expected hearing to decode")
+	}
+	if _, ok := decode([]byte(`{"type": `)); ok {
+		t.Fatalf("Expected truncated message to fail")
+	}
+	for _, typ := range []string{"bogus-1", "bogus-2"} {
+		if _, ok := decode([]byte(`{"type": "` + typ + `"}`)); !ok {
+			t.Fatalf("Expected %s to decode", typ)
+		}
+	}
+
+	if got := testutil.ToFloat64(metrics.NNGMessages.WithLabelValues("hearing")) - hearings; got != 1 {
+		t.Errorf("Expected 1 hearing counted, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.NNGMessages.WithLabelValues("other")) - others; got != 2 {
+		t.Errorf("Expected 2 unknown types counted as other, got %v", got)
+	}
+	if n := testutil.CollectAndCount(metrics.NNGMessages); n > 6 {
+		t.Errorf("Expected at most 6 type series, got %d", n)
+	}
+	if got := testutil.ToFloat64(metrics.NNGDecodeErrors) - errors; got != 1 {
+		t.Errorf("Expected 1 decode error counted, got %v", got)
+	}
+}
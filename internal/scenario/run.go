@@ -0,0 +1,57 @@
+package scenario
+
+import (
+	"context"
+	"time"
+)
+
+// RunOptions control the pace of Run.
+type RunOptions struct {
+	// Speed scales the timeline: 1 runs in real time, 10 ten times faster.
+	// Zero or less sends without waiting.
+	Speed float64
+	// Sleep waits for d or until ctx is done. It defaults to a timer and
+	// can be replaced to drive a run from a fake clock.
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Run publishes the encoded events of steps at their offsets until the
+// timeline ends or ctx is cancelled, and returns the number published.
+func Run(ctx context.Context, steps []Step, publish func([]byte) error, opts RunOptions) (int, error) {
+	if opts.Sleep == nil {
+		opts.Sleep = sleep
+	}
+	var prev time.Duration
+	sent := 0
+	for _, st := range steps {
+		if opts.Speed > 0 && st.At > prev {
+			if err := opts.Sleep(ctx, time.Duration(float64(st.At-prev)/opts.Speed)); err != nil {
+				return sent, err
+			}
+			prev = st.At
+		}
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		msg, err := st.Message()
+		if err != nil {
+			return sent, err
+		}
+		if err := publish(msg); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
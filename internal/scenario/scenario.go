@@ -0,0 +1,189 @@
+// Package scenario describes scripted reflector traffic in YAML or JSON and
+// turns it into a deterministic timeline of NNG events, so edge cases of the
+// dashboard's session logic can be reproduced by the simulator and tests.
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultProtocol is used for transmissions and links without a protocol.
+const DefaultProtocol = "DMR"
+
+// Scenario is a scripted timeline. All times are offsets from the start of
+// the run.
+type Scenario struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	// Duration is how long periodic state events are sent for. It defaults
+	// to the end of the last scripted event or transmission.
+	Duration time.Duration `mapstructure:"duration"`
+	// StateInterval is how often a state event is sent, starting at 0. Zero
+	// sends only the events listed in States.
+	StateInterval time.Duration `mapstructure:"state_interval"`
+	// StateDelay holds back every periodic state event: the snapshot is
+	// taken on the interval but arrives StateDelay later.
+	StateDelay time.Duration `mapstructure:"state_delay"`
+
+	Modules       []Module       `mapstructure:"modules"`
+	Clients       []Link         `mapstructure:"clients"`
+	Peers         []Link         `mapstructure:"peers"`
+	Transmissions []Transmission `mapstructure:"transmissions"`
+	States        []State        `mapstructure:"states"`
+}
+
+type Module struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+}
+
+// Link is a client or peer connected to the reflector between Connect and
+// Disconnect. A client connecting after 0 or disconnecting sends a
+// client_connect or client_disconnect event; peers only appear in state
+// events.
+type Link struct {
+	Callsign string `mapstructure:"callsign"`
+	Protocol string `mapstructure:"protocol"`
+	// Module is the module a client is linked to. Peers have none.
+	Module  string        `mapstructure:"module"`
+	Connect time.Duration `mapstructure:"connect"`
+	// Disconnect is zero for a link that stays connected.
+	Disconnect time.Duration `mapstructure:"disconnect"`
+}
+
+// Transmission is a talker keyed up on a module for Duration. Repeat sends
+// that many further talk bursts, each Gap after the previous one ends.
+type Transmission struct {
+	Callsign string        `mapstructure:"callsign"`
+	Module   string        `mapstructure:"module"`
+	Protocol string        `mapstructure:"protocol"`
+	Start    time.Duration `mapstructure:"start"`
+	Duration time.Duration `mapstructure:"duration"`
+	Repeat   int           `mapstructure:"repeat"`
+	Gap      time.Duration `mapstructure:"gap"`
+	// NoClosing drops the closing event at the end of each burst, leaving
+	// the talker to disappear from the ActiveTalkers of state events.
+	NoClosing bool `mapstructure:"no_closing"`
+}
+
+// State is a one-off state event with a snapshot taken At and sent Delay
+// later.
+type State struct {
+	At    time.Duration `mapstructure:"at"`
+	Delay time.Duration `mapstructure:"delay"`
+}
+
+// Load reads a scenario from a YAML or JSON file, chosen by its extension.
+func Load(path string) (*Scenario, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return decode(v)
+}
+
+// Read reads a scenario in format, "yaml" or "json", from r.
+func Read(r io.Reader, format string) (*Scenario, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(r); err != nil {
+		return nil, err
+	}
+	return decode(v)
+}
+
+func decode(v *viper.Viper) (*Scenario, error) {
+	var s Scenario
+	if err := v.Unmarshal(&s); err != nil {
+		return nil, err
+	}
+	s.normalize()
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// normalize upper-cases callsigns and modules as the reflector sends them
+// and fills in default protocols.
+func (s *Scenario) normalize() {
+	for i := range s.Modules {
+		s.Modules[i].Name = strings.ToUpper(strings.TrimSpace(s.Modules[i].Name))
+	}
+	for _, links := range [][]Link{s.Clients, s.Peers} {
+		for i := range links {
+			l := &links[i]
+			l.Callsign = strings.ToUpper(strings.TrimSpace(l.Callsign))
+			l.Module = strings.ToUpper(strings.TrimSpace(l.Module))
+			if l.Protocol == "" {
+				l.Protocol = DefaultProtocol
+			}
+		}
+	}
+	for i := range s.Transmissions {
+		tx := &s.Transmissions[i]
+		tx.Callsign = strings.ToUpper(strings.TrimSpace(tx.Callsign))
+		tx.Module = strings.ToUpper(strings.TrimSpace(tx.Module))
+		if tx.Protocol == "" {
+			tx.Protocol = DefaultProtocol
+		}
+	}
+}
+
+func (s *Scenario) validate() error {
+	var errs []error
+	for _, d := range []struct {
+		name string
+		d    time.Duration
+	}{{"duration", s.Duration}, {"state_interval", s.StateInterval}, {"state_delay", s.StateDelay}} {
+		if d.d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", d.name))
+		}
+	}
+	for i, l := range s.Clients {
+		errs = append(errs, l.validate(fmt.Sprintf("clients[%d]", i), true))
+	}
+	for i, l := range s.Peers {
+		errs = append(errs, l.validate(fmt.Sprintf("peers[%d]", i), false))
+	}
+	for i, tx := range s.Transmissions {
+		where := fmt.Sprintf("transmissions[%d]", i)
+		switch {
+		case tx.Callsign == "":
+			errs = append(errs, fmt.Errorf("%s: callsign is required", where))
+		case tx.Module == "":
+			errs = append(errs, fmt.Errorf("%s: module is required", where))
+		case tx.Duration <= 0:
+			errs = append(errs, fmt.Errorf("%s: duration must be positive", where))
+		case tx.Start < 0 || tx.Gap < 0 || tx.Repeat < 0:
+			errs = append(errs, fmt.Errorf("%s: start, gap and repeat must not be negative", where))
+		}
+	}
+	for i, st := range s.States {
+		if st.At < 0 || st.Delay < 0 {
+			errs = append(errs, fmt.Errorf("states[%d]: at and delay must not be negative", i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (l Link) validate(where string, client bool) error {
+	switch {
+	case l.Callsign == "":
+		return fmt.Errorf("%s: callsign is required", where)
+	case client && l.Module == "":
+		return fmt.Errorf("%s: module is required", where)
+	case l.Connect < 0 || l.Disconnect < 0:
+		return fmt.Errorf("%s: connect and disconnect must not be negative", where)
+	case l.Disconnect > 0 && l.Disconnect <= l.Connect:
+		return fmt.Errorf("%s: disconnect must be after connect", where)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/session"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+func read(t *testing.T, doc string) *Scenario {
+	t.Helper()
+	s, err := Read(strings.NewReader(doc), "yaml")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return s
+}
+
+func TestRead(t *testing.T) {
+	s := read(t, `
+name: test
+state_interval: 5s
+clients:
+  - callsign: " kf8s "
+    module: a
+transmissions:
+  - callsign: kf8s
+    module: a
+    start: 1s
+    duration: 1m30s
+    no_closing: true
+`)
+	if s.StateInterval != 5*time.Second {
+		t.Errorf("Expected state_interval 5s, got %v", s.StateInterval)
+	}
+	if c := s.Clients[0]; c.Callsign != "KF8S" || c.Module != "A" || c.Protocol != DefaultProtocol {
+		t.Errorf("Expected normalized client, got %+v", c)
+	}
+	if tx := s.Transmissions[0]; tx.Duration != 90*time.Second || !tx.NoClosing || tx.Protocol != DefaultProtocol {
+		t.Errorf("Expected decoded transmission, got %+v", tx)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want string
+	}{
+		{"missing callsign", "transmissions: [{module: A, duration: 1s}]", "transmissions[0]: callsign is required"},
+		{"zero duration", "transmissions: [{callsign: KF8S, module: A}]", "transmissions[0]: duration must be positive"},
+		{"client without module", "clients: [{callsign: KF8S}]", "clients[0]: module is required"},
+		{"disconnect before connect", "peers: [{callsign: XLX262, connect: 5s, disconnect: 2s}]", "peers[0]: disconnect must be after connect"},
+		{"negative delay", "state_delay: -1s", "state_delay must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.doc), "yaml")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadExamples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/scenarios/*")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("Expected example scenarios, got %v (%v)", paths, err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			s, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if len(s.Timeline(time.Now())) == 0 {
+				t.Error("Expected a non-empty timeline")
+			}
+		})
+	}
+}
+
+// summary renders a step as "offset type detail" for comparison.
+func summary(st Step) string {
+	ev := st.Event
+	detail := ev.My + ev.Callsign
+	if ev.Type == "state" {
+		var talkers []string
+		for _, a := range ev.ActiveTalkers {
+			talkers = append(talkers, a.Callsign)
+		}
+		var clients []string
+		for _, c := range ev.Clients {
+			clients = append(clients, c.Callsign)
+		}
+		detail = "talkers=" + strings.Join(talkers, ",") + " clients=" + strings.Join(clients, ",")
+	}
+	return st.At.String() + " " + ev.Type + " " + detail
+}
+
+func TestTimeline(t *testing.T) {
+	s := read(t, `
+state_interval: 10s
+clients:
+  - {callsign: KF8S, module: A}
+  - {callsign: N8DBF, module: B, connect: 3s, disconnect: 12s}
+transmissions:
+  - {callsign: KF8S, module: A, start: 2s, duration: 3s, repeat: 1, gap: 1s}
+  - {callsign: W8CPT, module: A, start: 4s, duration: 8s, no_closing: true}
+states:
+  - {at: 5s, delay: 6s}
+`)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := s.Timeline(start)
+
+	want := []string{
+		"0s state talkers= clients=KF8S",
+		"2s hearing KF8S",
+		"3s client_connect N8DBF",
+		"4s hearing W8CPT",
+		"5s closing KF8S",
+		"6s hearing KF8S",
+		"9s closing KF8S",
+		"10s state talkers=W8CPT clients=KF8S,N8DBF",
+		"11s state talkers=W8CPT clients=KF8S,N8DBF",
+		"12s client_disconnect N8DBF",
+	}
+	var got []string
+	for _, st := range steps {
+		got = append(got, summary(st))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Expected timeline\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// The delayed snapshot at 5s reflects KF8S's first key-up.
+	late := steps[8].Event
+	if len(late.Users) != 2 || late.Users[0].Callsign != "W8CPT" || !late.Users[1].LastHeard.Equal(start.Add(2*time.Second)) {
+		t.Errorf("Expected users heard by 5s, got %+v", late.Users)
+	}
+	if c := late.Clients[1]; !c.ConnectTime.Equal(start.Add(3 * time.Second)) {
+		t.Errorf("Expected connect time relative to start, got %v", c.ConnectTime)
+	}
+
+	msg, err := steps[1].Message()
+	if err != nil {
+		t.Fatalf("Message failed: %v", err)
+	}
+	var ev nng.Event
+	if err := json.Unmarshal(msg, &ev); err != nil || ev.Type != "hearing" || ev.My != "KF8S" || ev.Protocol != DefaultProtocol {
+		t.Errorf("Expected encoded hearing, got %s (%v)", msg, err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	steps := []Step{
+		{At: 0, Event: nng.Event{Type: "state"}},
+		{At: 2 * time.Second, Event: nng.Event{Type: "hearing", My: "KF8S"}},
+		{At: 2 * time.Second, Event: nng.Event{Type: "state"}},
+		{At: 6 * time.Second, Event: nng.Event{Type: "closing", My: "KF8S"}},
+	}
+
+	var slept []time.Duration
+	var sent []string
+	publish := func(msg []byte) error {
+		var ev nng.Event
+		_ = json.Unmarshal(msg, &ev)
+		sent = append(sent, ev.Type)
+		return nil
+	}
+	opts := RunOptions{Speed: 2, Sleep: func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}}
+	n, err := Run(context.Background(), steps, publish, opts)
+	if err != nil || n != 4 {
+		t.Fatalf("Expected 4 messages sent, got %d (%v)", n, err)
+	}
+	if strings.Join(sent, ",") != "state,hearing,state,closing" {
+		t.Errorf("Expected messages in order, got %v", sent)
+	}
+	if len(slept) != 2 || slept[0] != time.Second || slept[1] != 2*time.Second {
+		t.Errorf("Expected waits of 1s and 2s at speed 2, got %v", slept)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err = Run(ctx, steps, publish, RunOptions{Speed: 1})
+	if !errors.Is(err, context.Canceled) || n != 0 {
+		t.Errorf("Expected cancellation before sending, got %d (%v)", n, err)
+	}
+}
+
+// recorder is a session sink keeping hearings in memory.
+type recorder struct {
+	*store.Memory
+	events []nng.Event
+}
+
+func (r *recorder) Broadcast(ev nng.Event) {
+	r.events = append(r.events, ev)
+}
+
+// TestSessions plays scenarios through the session tracker on a clock
+// following the timeline.
+func TestSessions(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want map[string]float64 // duration by callsign
+	}{
+		{
+			name: "closing events",
+			doc: `
+state_interval: 5s
+transmissions:
+  - {callsign: KF8S, module: A, start: 1s, duration: 4s}
+`,
+			want: map[string]float64{"KF8S": 4},
+		},
+		{
+			name: "missing closing",
+			doc: `
+state_interval: 5s
+transmissions:
+  - {callsign: KF8S, module: A, duration: 10s, no_closing: true}
+`,
+			want: map[string]float64{"KF8S": 10},
+		},
+		{
+			name: "overlapping talkers",
+			doc: `
+state_interval: 5s
+transmissions:
+  - {callsign: KF8S, module: A, start: 1s, duration: 8s}
+  - {callsign: W8CPT, module: A, start: 3s, duration: 3s}
+  - {callsign: N8DBF, module: B, start: 2s, duration: 9s}
+`,
+			want: map[string]float64{"KF8S": 8, "W8CPT": 3, "N8DBF": 9},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			now := start
+			rec := &recorder{Memory: store.NewMemory()}
+			tr := session.NewTracker(rec)
+			tr.Clock = func() time.Time { return now }
+
+			for _, st := range read(t, tt.doc).Timeline(start) {
+				now = start.Add(st.At)
+				tr.Handle(st.Event)
+			}
+
+			if open := tr.Sessions(); len(open) != 0 {
+				t.Errorf("Expected no open sessions, got %+v", open)
+			}
+			hearings, err := rec.ListHearings(store.HearingFilter{})
+			if err != nil {
+				t.Fatalf("ListHearings failed: %v", err)
+			}
+			if len(hearings) != len(tt.want) {
+				t.Fatalf("Expected %d hearings, got %+v", len(tt.want), hearings)
+			}
+			for _, h := range hearings {
+				if h.Duration != tt.want[h.My] {
+					t.Errorf("Expected %s to last %vs, got %v", h.My, tt.want[h.My], h.Duration)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package scenario
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+)
+
+// Step is an event sent At an offset from the start of the run.
+type Step struct {
+	At    time.Duration
+	Event nng.Event
+}
+
+// Message encodes the event as the reflector publishes it.
+func (s Step) Message() ([]byte, error) {
+	return json.Marshal(s.Event)
+}
+
+// burst is one key-up of a transmission.
+type burst struct {
+	tx         *Transmission
+	start, end time.Duration
+}
+
+// Timeline returns the events of the scenario in the order they are sent.
+// Events at the same offset keep the order client events,
+// transmissions, then state events. Absolute times in state events, such as
+// ConnectTime and LastHeard, are relative to start, so the same start
+// always gives the same timeline.
+func (s *Scenario) Timeline(start time.Time) []Step {
+	start = start.UTC()
+	var steps []Step
+	add := func(at time.Duration, ev nng.Event) {
+		steps = append(steps, Step{At: at, Event: ev})
+	}
+
+	for _, c := range s.Clients {
+		if c.Connect > 0 {
+			add(c.Connect, nng.Event{Type: "client_connect", Callsign: c.Callsign, Module: c.Module, Protocol: c.Protocol})
+		}
+		if c.Disconnect > 0 {
+			add(c.Disconnect, nng.Event{Type: "client_disconnect", Callsign: c.Callsign, Module: c.Module, Protocol: c.Protocol})
+		}
+	}
+
+	bursts := s.bursts()
+	for _, b := range bursts {
+		add(b.start, nng.Event{
+			Type:     "hearing",
+			Module:   b.tx.Module,
+			Protocol: b.tx.Protocol,
+			My:       b.tx.Callsign,
+			Ur:       "CQCQCQ",
+			Rpt1:     "SIMULATOR",
+			Rpt2:     "URFD " + b.tx.Module,
+		})
+		if !b.tx.NoClosing {
+			add(b.end, nng.Event{Type: "closing", Module: b.tx.Module, Protocol: b.tx.Protocol, My: b.tx.Callsign})
+		}
+	}
+
+	end := s.Duration
+	if end == 0 {
+		for _, st := range steps {
+			end = max(end, st.At)
+		}
+		for _, b := range bursts {
+			end = max(end, b.end)
+		}
+		for _, st := range s.States {
+			end = max(end, st.At)
+		}
+	}
+	if s.StateInterval > 0 {
+		for at := time.Duration(0); at <= end; at += s.StateInterval {
+			add(at+s.StateDelay, s.snapshot(start, at, bursts))
+		}
+	}
+	for _, st := range s.States {
+		add(st.At+st.Delay, s.snapshot(start, st.At, bursts))
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].At < steps[j].At })
+	return steps
+}
+
+// bursts expands the transmissions into their key-ups, in scenario order.
+func (s *Scenario) bursts() []burst {
+	var out []burst
+	for i := range s.Transmissions {
+		tx := &s.Transmissions[i]
+		at := tx.Start
+		for range tx.Repeat + 1 {
+			out = append(out, burst{tx: tx, start: at, end: at + tx.Duration})
+			at += tx.Duration + tx.Gap
+		}
+	}
+	return out
+}
+
+// snapshot is the state event describing the reflector at offset at.
+func (s *Scenario) snapshot(start time.Time, at time.Duration, bursts []burst) nng.Event {
+	ev := nng.Event{Type: "state"}
+	for _, m := range s.Modules {
+		ev.Modules = append(ev.Modules, nng.Module{Name: m.Name, Description: m.Description})
+	}
+	for _, c := range s.Clients {
+		if c.connected(at) {
+			ev.Clients = append(ev.Clients, nng.Client{
+				Callsign:    c.Callsign,
+				Protocol:    c.Protocol,
+				OnModule:    c.Module,
+				ConnectTime: start.Add(c.Connect),
+			})
+		}
+	}
+	for _, p := range s.Peers {
+		if p.connected(at) {
+			ev.Peers = append(ev.Peers, nng.Peer{
+				Callsign:    p.Callsign,
+				Protocol:    p.Protocol,
+				ConnectTime: start.Add(p.Connect),
+			})
+		}
+	}
+
+	// Users lists everyone heard so far with their latest key-up, and
+	// ActiveTalkers those keyed up right now.
+	heard := make(map[string]int)
+	for _, b := range bursts {
+		if b.start > at {
+			continue
+		}
+		u := nng.User{
+			Callsign:  b.tx.Callsign,
+			Repeater:  "SIMULATOR",
+			OnModule:  b.tx.Module,
+			LastHeard: start.Add(b.start),
+		}
+		if i, ok := heard[u.Callsign]; !ok {
+			heard[u.Callsign] = len(ev.Users)
+			ev.Users = append(ev.Users, u)
+		} else if !u.LastHeard.Before(ev.Users[i].LastHeard) {
+			ev.Users[i] = u
+		}
+		if at < b.end {
+			ev.ActiveTalkers = append(ev.ActiveTalkers, nng.ActiveTalker{
+				Callsign: b.tx.Callsign,
+				Module:   b.tx.Module,
+				Protocol: b.tx.Protocol,
+			})
+		}
+	}
+	sort.SliceStable(ev.Users, func(i, j int) bool { return ev.Users[i].LastHeard.After(ev.Users[j].LastHeard) })
+	return ev
+}
+
+func (l Link) connected(at time.Duration) bool {
+	return l.Connect <= at && (l.Disconnect == 0 || at < l.Disconnect)
+}
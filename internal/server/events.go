@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventsKeepAlive is how often an idle event stream gets a comment line,
+// so proxies do not time it out.
+const eventsKeepAlive = 30 * time.Second
+
+// ServeEvents streams hub broadcasts as Server-Sent Events. Each broadcast
+// carries its hub ID, so a reconnecting client sending Last-Event-ID (or the
+// last_event_id query parameter) receives what it missed from the replay
+// ring. Clients starting afresh, too far behind to resume, or holding an ID
+// from before a restart get the OnConnect greeting instead. The same filter
+// parameters as /ws apply.
+func (s *Server) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	client := newClient(s.Hub, nil, FilterFromQuery(r.URL.Query()))
+	client.lastEventID = s.Hub.parseEventID(lastID)
+	if !s.Hub.register(client) {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer func() {
+		select {
+		case s.Hub.Unregister <- client:
+		case <-s.Hub.done:
+		}
+	}()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if !client.resumed && s.OnConnect != nil {
+		s.OnConnect(client)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-client.send.ready:
+			messages, ok := client.send.take()
+			for _, m := range messages {
+				if err := s.writeEvent(w, m); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+			if !ok {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// writeEvent writes m in event stream format. Messages sent to this client
+// only have no ID, so they do not move the client's resume point.
+func (s *Server) writeEvent(w http.ResponseWriter, m *Message) error {
+	if m.ID > 0 {
+		if _, err := fmt.Fprintf(w, "id: %s\n", s.Hub.EventID(m.ID)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "data: %s\n\n", m.Data)
+	return err
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one parsed event stream record.
+type sseEvent struct {
+	id   string
+	data string
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("ReadString failed: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if ev.data != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestServeEvents(t *testing.T) {
+	hub := NewHub()
+	hub.ReplaySize = 3
+	hub.epoch = "e1"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	srv := NewServer(hub, nil)
+	srv.OnConnect = func(c *Client) {
+		c.SendJSON(map[string]string{"type": "greeting"})
+	}
+	s := httptest.NewServer(http.HandlerFunc(srv.ServeEvents))
+	defer s.Close()
+
+	for _, typ := range []string{"one", "two", "three", "four"} {
+		hub.BroadcastJSON(map[string]string{"type": typ})
+	}
+
+	tests := []struct {
+		name   string
+		lastID string
+		query  string
+		want   []sseEvent
+	}{
+		{
+			name: "Fresh",
+			want: []sseEvent{{data: `{"type":"greeting"}`}, {id: "e1-5", data: `{"type":"five"}`}},
+		},
+		{
+			name:   "Resume",
+			lastID: "e1-2",
+			want: []sseEvent{
+				{id: "e1-3", data: `{"type":"three"}`},
+				{id: "e1-4", data: `{"type":"four"}`},
+				{id: "e1-5", data: `{"type":"five"}`},
+			},
+		},
+		{
+			name:   "Resume Filtered",
+			lastID: "e1-2",
+			query:  "?types=four,five",
+			want:   []sseEvent{{id: "e1-4", data: `{"type":"four"}`}, {id: "e1-5", data: `{"type":"five"}`}},
+		},
+		{
+			// Message 2 has left the ring, so the client is greeted after
+			// what remains
+			name:   "Too Old",
+			lastID: "e1-1",
+			want: []sseEvent{
+				{id: "e1-3", data: `{"type":"three"}`},
+				{id: "e1-4", data: `{"type":"four"}`},
+				{id: "e1-5", data: `{"type":"five"}`},
+				{data: `{"type":"greeting"}`},
+			},
+		},
+		{
+			name:   "Unknown ID",
+			lastID: "e1-99",
+			want:   []sseEvent{{data: `{"type":"greeting"}`}},
+		},
+		{
+			// IDs restart with every process, so an ID from an earlier run
+			// cannot be resumed even though it is in range
+			name:   "Earlier Run",
+			lastID: "e0-2",
+			want:   []sseEvent{{data: `{"type":"greeting"}`}},
+		},
+		{
+			name:   "Malformed ID",
+			lastID: "2",
+			want:   []sseEvent{{data: `{"type":"greeting"}`}},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", s.URL+tt.query, nil)
+			if tt.lastID != "" {
+				req.Header.Set("Last-Event-ID", tt.lastID)
+			}
+			reqCtx, stop := context.WithTimeout(ctx, 5*time.Second)
+			defer stop()
+			resp, err := http.DefaultClient.Do(req.WithContext(reqCtx))
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Expected text/event-stream, got %s", ct)
+			}
+			r := bufio.NewReader(resp.Body)
+
+			if i == 0 {
+				// The first live broadcast, seen by every later case
+				hub.BroadcastJSON(map[string]string{"type": "five"})
+			}
+			for _, want := range tt.want {
+				if got := readEvent(t, r); got != want {
+					t.Errorf("Expected %+v, got %+v", want, got)
+				}
+			}
+		})
+	}
+}
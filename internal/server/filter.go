@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Message is a broadcast payload along with the fields clients can filter
+// on, read from its "type", "reflector", "module", "my" and "callsign" keys.
+type Message struct {
+	// ID numbers broadcasts in order; it is zero for messages sent to a
+	// single client.
+	ID        uint64
+	Type      string
+	Reflector string
+	Module    string
+	Callsign  string
+	Data      []byte
+}
+
+// NewMessage wraps an encoded JSON object as a Message.
+func NewMessage(data []byte) *Message {
+	var meta struct {
+		Type      string `json:"type"`
+		Reflector string `json:"reflector"`
+		Module    string `json:"module"`
+		My        string `json:"my"`
+		Callsign  string `json:"callsign"`
+	}
+	_ = json.Unmarshal(data, &meta)
+
+	m := &Message{Type: meta.Type, Reflector: meta.Reflector, Module: meta.Module, Callsign: meta.My, Data: data}
+	if m.Callsign == "" {
+		m.Callsign = meta.Callsign
+	}
+	return m
+}
+
+// Filter selects the messages a client receives. Empty lists match
+// everything. Types also match related message types: "hearing" includes
+// "closing" and "state" includes "state_diff". Reflectors, Modules and
+// Callsigns only apply to messages that carry a reflector, module or
+// callsign.
+type Filter struct {
+	Types      []string `json:"types,omitempty"`
+	Reflectors []string `json:"reflectors,omitempty"`
+	Modules    []string `json:"modules,omitempty"`
+	Callsigns  []string `json:"callsigns,omitempty"`
+}
+
+// typeGroups maps message types to the subscription type covering them.
+var typeGroups = map[string]string{
+	"closing":    "hearing",
+	"state_diff": "state",
+}
+
+func (f *Filter) Match(m *Message) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, m.Type) {
+		group, ok := typeGroups[m.Type]
+		if !ok || !slices.Contains(f.Types, group) {
+			return false
+		}
+	}
+	if len(f.Reflectors) > 0 && m.Reflector != "" && !slices.Contains(f.Reflectors, m.Reflector) {
+		return false
+	}
+	if len(f.Modules) > 0 && m.Module != "" && !slices.Contains(f.Modules, m.Module) {
+		return false
+	}
+	if len(f.Callsigns) > 0 && m.Callsign != "" && !slices.Contains(f.Callsigns, m.Callsign) {
+		return false
+	}
+	return true
+}
+
+// FilterFromQuery reads comma-separated types, reflectors, modules and
+// callsigns parameters, so embeds can subscribe in the URL (e.g.
+// /ws?types=hearing&modules=B).
+func FilterFromQuery(q url.Values) Filter {
+	list := func(key string) []string {
+		var out []string
+		for _, v := range strings.Split(q.Get(key), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	f := Filter{Types: list("types"), Reflectors: list("reflectors"), Modules: list("modules"), Callsigns: list("callsigns")}
+	f.normalize()
+	return f
+}
+
+// normalize upper-cases modules and callsigns as the reflector sends them.
+// Reflector IDs are only trimmed.
+func (f *Filter) normalize() {
+	for i := range f.Reflectors {
+		f.Reflectors[i] = strings.TrimSpace(f.Reflectors[i])
+	}
+	for i := range f.Modules {
+		f.Modules[i] = strings.ToUpper(strings.TrimSpace(f.Modules[i]))
+	}
+	for i := range f.Callsigns {
+		f.Callsigns[i] = strings.ToUpper(strings.TrimSpace(f.Callsigns[i]))
+	}
+}
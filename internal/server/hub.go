@@ -1,66 +1,336 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-// Client is a middleman between the websocket connection and the hub.
+// DefaultQueueSize is the number of unsent messages kept per client before
+// the oldest are dropped.
+const DefaultQueueSize = 256
+
+// publishBuffer bounds broadcasts waiting for the hub goroutine, so
+// publishing never blocks on a busy hub.
+const publishBuffer = 4096
+
+// DefaultReplaySize is the number of recent broadcasts kept for clients
+// resuming an event stream.
+const DefaultReplaySize = 512
+
+// Websocket keepalive defaults. Pings go out often enough that a healthy
+// client's pong always lands before the read deadline.
+const (
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultPongTimeout  = 60 * time.Second
+	DefaultPingInterval = DefaultPongTimeout * 9 / 10
+)
+
+// maxClientMessage bounds the size of messages read from a browser, which
+// only ever sends small subscribe requests.
+const maxClientMessage = 4096
+
+// Client is a middleman between a websocket connection or event stream and
+// the hub. Conn is nil for event streams.
 type Client struct {
 	Hub  *Hub
 	Conn *websocket.Conn
-	Send chan []byte
+
+	// send holds messages not yet written. A slow client loses its oldest
+	// messages rather than holding up the hub.
+	send *queue
+
+	mu     sync.RWMutex
+	filter Filter
+
+	// lastEventID asks the hub to replay broadcasts after this ID on
+	// registration; ready is closed once it has, with resumed reporting
+	// whether the replay covered everything since lastEventID.
+	lastEventID uint64
+	ready       chan struct{}
+	resumed     bool
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, f Filter) *Client {
+	return &Client{Hub: hub, Conn: conn, send: newQueue(hub.QueueSize), filter: f}
+}
+
+// Subscribe replaces the client's filter.
+func (c *Client) Subscribe(f Filter) {
+	f.normalize()
+	c.mu.Lock()
+	c.filter = f
+	c.mu.Unlock()
+}
+
+// Wants reports whether m passes the client's filter.
+func (c *Client) Wants(m *Message) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter.Match(m)
+}
+
+// Dropped returns the number of messages this client missed because it
+// fell behind.
+func (c *Client) Dropped() uint64 {
+	return c.send.drops()
+}
+
+// SendJSON queues v for this client only, subject to its filter. It is
+// used to greet new clients with the current state.
+func (c *Client) SendJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("JSON Marshal error: %v", err)
+		return
+	}
+	if m := NewMessage(data); c.Wants(m) {
+		c.enqueue(m)
+	}
+}
+
+// enqueue queues m, counting anything dropped against the hub.
+func (c *Client) enqueue(m *Message) bool {
+	if n := c.send.push(m); n > 0 {
+		c.Hub.dropped.Add(uint64(n))
+		return false
+	}
+	return true
 }
 
 type Hub struct {
 	Clients    map[*Client]bool
-	Broadcast  chan []byte
 	Register   chan *Client
 	Unregister chan *Client
+
+	// QueueSize bounds each client's queue. Set before clients connect.
+	QueueSize int
+
+	// inbox holds published messages until Run fans them out.
+	inbox *queue
+
+	// ReplaySize bounds the ring of recent broadcasts. Set before Run.
+	ReplaySize int
+	replay     []*Message
+	lastID     uint64
+	// epoch identifies this run of the hub, since IDs restart at 1 with
+	// every process.
+	epoch string
+
+	// WriteTimeout bounds each websocket write. A client that has not
+	// answered a ping within PongTimeout is disconnected; PingInterval
+	// must be shorter than PongTimeout.
+	WriteTimeout time.Duration
+	PongTimeout  time.Duration
+	PingInterval time.Duration
+
+	clients atomic.Int64
+	dropped atomic.Uint64
+
+	// ping is received by Run to show it is still responsive.
+	ping chan struct{}
+
+	// done is closed when Run returns, so senders never block on a hub
+	// that has stopped.
+	done  chan struct{}
+	pumps sync.WaitGroup
 }
 
+// ErrHubStopped is returned by Ping once Run has returned.
+var ErrHubStopped = errors.New("hub stopped")
+
 func NewHub() *Hub {
 	return &Hub{
-		Broadcast:  make(chan []byte),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		Clients:    make(map[*Client]bool),
+		Register:     make(chan *Client),
+		Unregister:   make(chan *Client),
+		Clients:      make(map[*Client]bool),
+		QueueSize:    DefaultQueueSize,
+		inbox:        newQueue(publishBuffer),
+		ReplaySize:   DefaultReplaySize,
+		WriteTimeout: DefaultWriteTimeout,
+		PongTimeout:  DefaultPongTimeout,
+		PingInterval: DefaultPingInterval,
+		ping:         make(chan struct{}),
+		done:         make(chan struct{}),
+		epoch:        strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// EventID is the event stream ID of broadcast id, "<epoch>-<id>".
+func (h *Hub) EventID(id uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(id, 10)
+}
+
+// parseEventID returns the broadcast ID of an event stream ID, or zero if
+// it is malformed or from another run of the hub.
+func (h *Hub) parseEventID(s string) uint64 {
+	epoch, n, ok := strings.Cut(s, "-")
+	if !ok || epoch != h.epoch {
+		return 0
 	}
+	id, _ := strconv.ParseUint(n, 10, 64)
+	return id
 }
 
-func (h *Hub) Run() {
+// ClientCount returns the number of connected websocket and event stream
+// clients.
+func (h *Hub) ClientCount() int {
+	return int(h.clients.Load())
+}
+
+// Dropped returns the number of messages dropped because a client, or the
+// hub itself, fell behind.
+func (h *Hub) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// Pending returns the number of published messages the hub has yet to fan
+// out.
+func (h *Hub) Pending() int {
+	return h.inbox.size()
+}
+
+// Ping waits for the hub goroutine to take a turn, returning ctx's error if
+// it is wedged.
+func (h *Hub) Ping(ctx context.Context) error {
+	select {
+	case h.ping <- struct{}{}:
+		return nil
+	case <-h.done:
+		return ErrHubStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run fans out broadcasts until ctx is cancelled. It then closes every
+// client's queue and waits for their write pumps to flush and close the
+// connections.
+func (h *Hub) Run(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			close(h.done)
+			for client := range h.Clients {
+				client.send.close()
+				delete(h.Clients, client)
+			}
+			h.clients.Store(0)
+			h.pumps.Wait()
+			return
 		case client := <-h.Register:
 			h.Clients[client] = true
+			h.clients.Store(int64(len(h.Clients)))
+			if client.lastEventID > 0 {
+				client.resumed = h.resume(client)
+			}
+			if client.ready != nil {
+				close(client.ready)
+			}
 		case client := <-h.Unregister:
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
-				close(client.Send)
+				client.send.close()
+				h.clients.Store(int64(len(h.Clients)))
 			}
-		case message := <-h.Broadcast:
-			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+		case <-h.ping:
+		case <-h.inbox.ready:
+			messages, _ := h.inbox.take()
+			for _, message := range messages {
+				h.fanOut(message)
 			}
 		}
 	}
 }
 
+// fanOut numbers message and queues it for every client that wants it.
+func (h *Hub) fanOut(message *Message) {
+	h.lastID++
+	message.ID = h.lastID
+	h.remember(message)
+	for client := range h.Clients {
+		if client.Wants(message) {
+			client.enqueue(message)
+		}
+	}
+}
+
+// remember appends m to the replay ring, dropping the oldest message when
+// full.
+func (h *Hub) remember(m *Message) {
+	if h.ReplaySize <= 0 {
+		return
+	}
+	if len(h.replay) >= h.ReplaySize {
+		copy(h.replay, h.replay[1:])
+		h.replay = h.replay[:len(h.replay)-1]
+	}
+	h.replay = append(h.replay, m)
+}
+
+// resume queues the remembered broadcasts after c.lastEventID. It reports
+// false if some of them have already left the ring or do not fit in the
+// client's queue.
+func (h *Hub) resume(c *Client) bool {
+	if c.lastEventID > h.lastID {
+		return false
+	}
+	complete := c.lastEventID == h.lastID ||
+		(len(h.replay) > 0 && h.replay[0].ID <= c.lastEventID+1)
+	for _, m := range h.replay {
+		if m.ID > c.lastEventID && c.Wants(m) && !c.enqueue(m) {
+			complete = false
+		}
+	}
+	return complete
+}
+
+// register adds c to the hub and waits until any replay is queued. It
+// reports false if the hub has stopped.
+func (h *Hub) register(c *Client) bool {
+	c.ready = make(chan struct{})
+	select {
+	case h.Register <- c:
+	case <-h.done:
+		return false
+	}
+	select {
+	case <-c.ready:
+		return true
+	case <-h.done:
+		return false
+	}
+}
+
+// Publish queues m for every interested client. It never blocks: if the
+// hub falls far behind, the oldest unpublished messages are dropped.
+func (h *Hub) Publish(m *Message) {
+	select {
+	case <-h.done:
+		return
+	default:
+	}
+	if n := h.inbox.push(m); n > 0 {
+		h.dropped.Add(uint64(n))
+	}
+}
+
 func (h *Hub) BroadcastJSON(v interface{}) {
 	data, err := json.Marshal(v)
 	if err != nil {
 		log.Printf("JSON Marshal error: %v", err)
 		return
 	}
-	h.Broadcast <- data
+	h.Publish(NewMessage(data))
 }
 
 func UpgradeAndRegister(hub *Hub, w http.ResponseWriter, r *http.Request) (*websocket.Conn, *Client) {
@@ -69,8 +339,17 @@ func UpgradeAndRegister(hub *Hub, w http.ResponseWriter, r *http.Request) (*webs
 		log.Printf("WS Upgrade error: %v", err)
 		return nil, nil
 	}
-	client := &Client{Hub: hub, Conn: conn, Send: make(chan []byte, 256)}
-	client.Hub.Register <- client
+	client := newClient(hub, conn, FilterFromQuery(r.URL.Query()))
+	// Count the write pump before registering so Run cannot stop waiting
+	// for it early.
+	hub.pumps.Add(1)
+	select {
+	case client.Hub.Register <- client:
+	case <-hub.done:
+		hub.pumps.Done()
+		_ = conn.Close()
+		return nil, nil
+	}
 	return conn, client
 }
 
@@ -78,18 +357,81 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	_, client := UpgradeAndRegister(hub, w, r)
 	if client != nil {
 		go client.WritePump()
+		go client.ReadPump()
 	}
 }
 
-func (c *Client) WritePump() {
+// clientRequest is a message sent by the browser, e.g.
+// {"type": "subscribe", "types": ["hearing"], "modules": ["B"]}.
+type clientRequest struct {
+	Type string `json:"type"`
+	Filter
+}
+
+// ReadPump handles messages from the client until the connection closes
+// or stops answering pings.
+func (c *Client) ReadPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+		}
 		_ = c.Conn.Close()
 	}()
-	for message := range c.Send {
-		if err := c.Conn.WriteMessage(websocket.TextMessage, message); err != nil {
+	c.Conn.SetReadLimit(maxClientMessage)
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.Hub.PongTimeout))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(c.Hub.PongTimeout))
+	})
+	for {
+		_, data, err := c.Conn.ReadMessage()
+		if err != nil {
 			return
 		}
+		var req clientRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			log.Printf("WS invalid client message: %v", err)
+			continue
+		}
+		if req.Type == "subscribe" {
+			c.Subscribe(req.Filter)
+		}
+	}
+}
+
+// WritePump writes queued messages and pings to the client until the hub
+// closes its queue or a write fails.
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(c.Hub.PingInterval)
+	defer func() {
+		ticker.Stop()
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+		}
+		_ = c.Conn.Close()
+		c.Hub.pumps.Done()
+	}()
+	for {
+		select {
+		case <-c.send.ready:
+			messages, ok := c.send.take()
+			for _, message := range messages {
+				_ = c.Conn.SetWriteDeadline(time.Now().Add(c.Hub.WriteTimeout))
+				if err := c.Conn.WriteMessage(websocket.TextMessage, message.Data); err != nil {
+					return
+				}
+			}
+			if !ok {
+				_ = c.Conn.SetWriteDeadline(time.Now().Add(c.Hub.WriteTimeout))
+				_ = c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+		case <-ticker.C:
+			_ = c.Conn.SetWriteDeadline(time.Now().Add(c.Hub.WriteTimeout))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
-	_ = c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 }
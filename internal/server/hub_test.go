@@ -1,18 +1,22 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 func TestHub(t *testing.T) {
 	hub := NewHub()
-	go hub.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
 
 	NewServer(hub, nil)
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -45,3 +49,164 @@ func TestHub(t *testing.T) {
 		t.Errorf("Expected type test, got %s", resp["type"])
 	}
 }
+
+func TestHubSubscribe(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer s.Close()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws?types=hearing"
+	ws, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = ws.Close() }()
+
+	read := func() map[string]string {
+		t.Helper()
+		_, p, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		var resp map[string]string
+		if err := json.Unmarshal(p, &resp); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		return resp
+	}
+
+	// Query string filter: state is skipped, closing counts as hearing
+	hub.BroadcastJSON(map[string]string{"type": "state_diff"})
+	hub.BroadcastJSON(map[string]string{"type": "closing", "my": "KF8S", "module": "A"})
+	if resp := read(); resp["type"] != "closing" {
+		t.Fatalf("Expected closing, got %v", resp)
+	}
+
+	// Narrow to module B over the socket
+	if err := ws.WriteJSON(map[string]interface{}{"type": "subscribe", "types": []string{"hearing"}, "modules": []string{"b"}}); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	for {
+		hub.BroadcastJSON(map[string]string{"type": "hearing", "my": "KF8S", "module": "A"})
+		hub.BroadcastJSON(map[string]string{"type": "hearing", "my": "W8CPT", "module": "B"})
+		if resp := read(); resp["module"] == "B" {
+			break
+		}
+		// The subscription was not applied yet; skip the module B copy
+		if resp := read(); resp["module"] != "B" {
+			t.Fatalf("Expected module B, got %v", resp)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		msg    string
+		want   bool
+	}{
+		{name: "Empty Filter", filter: Filter{}, msg: `{"type": "state"}`, want: true},
+		{name: "Type Group", filter: Filter{Types: []string{"state"}}, msg: `{"type": "state_diff"}`, want: true},
+		{name: "Other Type", filter: Filter{Types: []string{"hearing"}}, msg: `{"type": "connection"}`, want: false},
+		{name: "Module", filter: Filter{Modules: []string{"B"}}, msg: `{"type": "hearing", "module": "A"}`, want: false},
+		{name: "No Module", filter: Filter{Modules: []string{"B"}}, msg: `{"type": "state_diff"}`, want: true},
+		{name: "Reflector", filter: Filter{Reflectors: []string{"URF002"}}, msg: `{"type": "state_diff", "reflector": "URF001"}`, want: false},
+		{name: "Same Reflector", filter: Filter{Reflectors: []string{"URF002"}}, msg: `{"type": "hearing", "reflector": "URF002", "module": "A"}`, want: true},
+		{name: "Callsign", filter: Filter{Callsigns: []string{"KF8S"}}, msg: `{"type": "hearing", "my": "KF8S"}`, want: true},
+		{name: "Connect Callsign", filter: Filter{Callsigns: []string{"KF8S"}}, msg: `{"type": "client_connect", "callsign": "N7TAE"}`, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(NewMessage([]byte(tt.msg))); got != tt.want {
+				t.Errorf("Match(%s) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHubDropsDeadClient(t *testing.T) {
+	hub := NewHub()
+	hub.PingInterval = 20 * time.Millisecond
+	hub.PongTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hub.Run(ctx)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer s.Close()
+
+	u := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+	live, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = live.Close() }()
+	// Reading answers pings
+	go func() {
+		for {
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Never reads, so never answers a ping
+	dead, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = dead.Close() }()
+
+	waitFor := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for hub.ClientCount() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d clients, got %d", want, hub.ClientCount())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(2)
+	waitFor(1)
+
+	// The live client outlasts several pong timeouts
+	time.Sleep(3 * hub.PongTimeout)
+	if n := hub.ClientCount(); n != 1 {
+		t.Errorf("Expected 1 client, got %d", n)
+	}
+}
+
+func TestHubPing(t *testing.T) {
+	hub := NewHub()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hub.Ping(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected deadline exceeded before Run, got %v", err)
+	}
+
+	runCtx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hub.Run(runCtx)
+		close(done)
+	}()
+	if err := hub.Ping(context.Background()); err != nil {
+		t.Errorf("Expected running hub to answer, got %v", err)
+	}
+
+	stop()
+	<-done
+	if err := hub.Ping(context.Background()); err != ErrHubStopped {
+		t.Errorf("Expected ErrHubStopped, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import "sync"
+
+// queue is a bounded FIFO of messages that never blocks the producer. When
+// full it drops the oldest message. Messages whose type coalesces replace
+// any queued message of the same type and reflector, since only the latest
+// matters.
+type queue struct {
+	mu      sync.Mutex
+	items   []*Message
+	max     int
+	closed  bool
+	dropped uint64
+
+	// ready holds a token while the queue has items or is closed.
+	ready chan struct{}
+}
+
+func newQueue(max int) *queue {
+	return &queue{max: max, ready: make(chan struct{}, 1)}
+}
+
+// coalesces reports whether a newer message of m's type supersedes older
+// ones: full state snapshots and link status.
+func coalesces(m *Message) bool {
+	return m.Type == "state" || m.Type == "connection"
+}
+
+// push appends m, returning the number of messages dropped to make room.
+// Pushing to a closed queue is a no-op.
+func (q *queue) push(m *Message) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return 0
+	}
+
+	if coalesces(m) {
+		kept := q.items[:0]
+		for _, old := range q.items {
+			if old.Type != m.Type || old.Reflector != m.Reflector {
+				kept = append(kept, old)
+			}
+		}
+		clear(q.items[len(kept):])
+		q.items = kept
+	}
+
+	dropped := 0
+	if q.max > 0 && len(q.items) >= q.max {
+		dropped = len(q.items) - q.max + 1
+		clear(q.items[:dropped])
+		q.items = q.items[dropped:]
+		q.dropped += uint64(dropped)
+	}
+	q.items = append(q.items, m)
+	q.signal()
+	return dropped
+}
+
+// take removes and returns everything queued. ok is false once the queue is
+// closed and drained.
+func (q *queue) take() (items []*Message, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, q.items = q.items, nil
+	if q.closed {
+		if len(items) == 0 {
+			return nil, false
+		}
+		// Leave a token so the consumer wakes to see the queue closed.
+		q.signal()
+	}
+	return items, true
+}
+
+// close stops further pushes. Queued messages can still be taken.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.signal()
+}
+
+// drops returns the number of messages dropped so far.
+func (q *queue) drops() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// size returns the number of queued messages.
+func (q *queue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// signal leaves a token in ready. Callers must hold q.mu.
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
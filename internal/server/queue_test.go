@@ -0,0 +1,121 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueue(t *testing.T) {
+	// Messages are written as type or type@reflector
+	msg := func(s string) *Message {
+		typ, reflector, _ := strings.Cut(s, "@")
+		return &Message{Type: typ, Reflector: reflector}
+	}
+	name := func(m *Message) string {
+		if m.Reflector != "" {
+			return m.Type + "@" + m.Reflector
+		}
+		return m.Type
+	}
+
+	tests := []struct {
+		name    string
+		max     int
+		push    []string
+		want    []string
+		dropped uint64
+	}{
+		{
+			name: "Under Limit",
+			max:  3,
+			push: []string{"hearing", "closing"},
+			want: []string{"hearing", "closing"},
+		},
+		{
+			name:    "Drops Oldest",
+			max:     2,
+			push:    []string{"hearing", "closing", "state_diff"},
+			want:    []string{"closing", "state_diff"},
+			dropped: 1,
+		},
+		{
+			name: "Coalesces Latest State",
+			max:  3,
+			push: []string{"state", "connection", "state_diff", "state", "connection"},
+			want: []string{"state_diff", "state", "connection"},
+		},
+		{
+			name: "Coalesces Per Reflector",
+			max:  4,
+			push: []string{"state@URF001", "state@URF002", "connection@URF001", "state@URF001"},
+			want: []string{"state@URF002", "connection@URF001", "state@URF001"},
+		},
+		{
+			// Replacing queued snapshots makes room, so nothing is dropped
+			name: "Coalesce Before Drop",
+			max:  2,
+			push: []string{"hearing", "state", "state"},
+			want: []string{"hearing", "state"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQueue(tt.max)
+			for _, typ := range tt.push {
+				q.push(msg(typ))
+			}
+			items, ok := q.take()
+			if !ok {
+				t.Fatalf("Expected open queue")
+			}
+			got := []string{}
+			for _, m := range items {
+				got = append(got, name(m))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			if d := q.drops(); d != tt.dropped {
+				t.Errorf("Expected %d dropped, got %d", tt.dropped, d)
+			}
+		})
+	}
+}
+
+func TestQueueClose(t *testing.T) {
+	q := newQueue(2)
+	q.push(&Message{Type: "hearing"})
+	q.close()
+	q.push(&Message{Type: "closing"})
+
+	// Consume like WritePump: wait for the token, then take
+	<-q.ready
+	items, ok := q.take()
+	if !ok || len(items) != 1 {
+		t.Fatalf("Expected the message queued before close, got %d (ok %v)", len(items), ok)
+	}
+	select {
+	case <-q.ready:
+	default:
+		t.Fatal("Expected a ready token after taking the last messages of a closed queue")
+	}
+	if _, ok := q.take(); ok {
+		t.Errorf("Expected drained queue to report closed")
+	}
+}
+
+func TestHubPublishDoesNotBlock(t *testing.T) {
+	// No Run: nothing drains the hub
+	hub := NewHub()
+	for i := 0; i < publishBuffer+10; i++ {
+		hub.BroadcastJSON(map[string]string{"type": "hearing"})
+	}
+	if n := hub.Pending(); n != publishBuffer {
+		t.Errorf("Expected %d pending, got %d", publishBuffer, n)
+	}
+	if d := hub.Dropped(); d != 10 {
+		t.Errorf("Expected 10 dropped, got %d", d)
+	}
+}
@@ -1,40 +1,70 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"io/fs"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// ShutdownTimeout bounds how long Start waits for in-flight requests once
+// its context is cancelled.
+const ShutdownTimeout = 10 * time.Second
+
 type Server struct {
 	Hub       *Hub
 	Assets    fs.FS
+	Mux       *http.ServeMux
 	OnConnect func(*Client)
+
+	// closing is closed when shutdown begins, ending event streams that
+	// would otherwise hold it up.
+	closing chan struct{}
+	routes  sync.Once
 }
 
 func NewServer(hub *Hub, assets fs.FS) *Server {
-	return &Server{Hub: hub, Assets: assets}
+	return &Server{Hub: hub, Assets: assets, Mux: http.NewServeMux(), closing: make(chan struct{})}
 }
 
-func (s *Server) Start(addr string) error {
+// HandleFunc registers an additional handler, such as an API route.
+func (s *Server) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.Mux.HandleFunc(pattern, handler)
+}
+
+// Handler adds the websocket, event stream and static file routes to Mux
+// and returns it. Static files are served for paths no other route of Mux
+// matches, whenever that route was added.
+func (s *Server) Handler() http.Handler {
+	s.routes.Do(s.register)
+	return s.Mux
+}
+
+func (s *Server) register() {
 	// Handle WS
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	s.Mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		_, client := UpgradeAndRegister(s.Hub, w, r)
 		if client != nil {
 			if s.OnConnect != nil {
 				s.OnConnect(client)
 			}
 			go client.WritePump()
+			go client.ReadPump()
 		}
 	})
 
+	s.Mux.HandleFunc("GET /api/events", s.ServeEvents)
+
 	// Handle Static Files (with SPA routing support)
 	fileServer := http.FileServer(http.FS(s.Assets))
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	s.Mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// If requesting a file that doesn't exist, serve index.html for SPA
 		path := r.URL.Path
 		if path == "/" {
@@ -52,9 +82,26 @@ func (s *Server) Start(addr string) error {
 		_ = f.Close()
 		fileServer.ServeHTTP(w, r)
 	})
+}
+
+// Start serves HTTP on addr until ctx is cancelled, then shuts down
+// gracefully. It returns nil after a graceful shutdown.
+func (s *Server) Start(ctx context.Context, addr string) error {
+	httpSrv := &http.Server{Addr: addr, Handler: s.Handler()}
+	httpSrv.RegisterOnShutdown(func() { close(s.closing) })
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		shutdownErr <- httpSrv.Shutdown(shutdownCtx)
+	}()
 
 	log.Printf("HTTP Server starting on %s", addr)
-	return http.ListenAndServe(addr, nil)
+	if err := httpSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-shutdownErr
 }
 
 var upgrader = websocket.Upgrader{
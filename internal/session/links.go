@@ -0,0 +1,232 @@
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+// LinkSink persists connection sessions of clients and peers.
+type LinkSink interface {
+	OpenConnection(c *store.Connection) error
+	CloseConnection(id uint, at time.Time) error
+}
+
+// relinkSlack is how much later than an open connection's start a reported
+// ConnectTime must be to count as a relink, allowing for clock skew between
+// the reflector and a connection opened from a client_connect event.
+const relinkSlack = 5 * time.Second
+
+// linkKey identifies an open connection. A client moving to another module
+// starts a new connection.
+type linkKey struct {
+	kind     string
+	callsign string
+	module   string
+}
+
+// LinkTracker derives client and peer connection sessions by diffing
+// successive state snapshots, and from client_connect/client_disconnect
+// events when the reflector sends them.
+type LinkTracker struct {
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+	// Reflector tags the connections of this tracker. Each reflector needs
+	// its own tracker.
+	Reflector string
+
+	sink LinkSink
+	log  *zap.Logger
+	mu   sync.Mutex
+	open map[linkKey]*store.Connection
+}
+
+func NewLinkTracker(sink LinkSink) *LinkTracker {
+	return &LinkTracker{
+		Clock: time.Now,
+		sink:  sink,
+		log:   zap.L(),
+		open:  make(map[linkKey]*store.Connection),
+	}
+}
+
+func keyOf(c *store.Connection) linkKey {
+	return linkKey{kind: c.Kind, callsign: c.Callsign, module: c.Module}
+}
+
+// Load seeds the tracker with connections left open by a previous run, so
+// links that survived a dashboard restart continue their session. One the
+// next snapshot reports with a later ConnectTime relinked meanwhile and is
+// closed.
+func (l *LinkTracker) Load(conns []store.Connection) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range conns {
+		c := conns[i]
+		l.open[keyOf(&c)] = &c
+	}
+}
+
+// Handle dispatches state and client connect/disconnect events.
+func (l *LinkTracker) Handle(ev nng.Event) {
+	switch ev.Type {
+	case "state":
+		l.State(ev)
+	case "client_connect":
+		l.Connect(ev)
+	case "client_disconnect":
+		l.Disconnect(ev)
+	}
+}
+
+// State opens connections for clients and peers that appeared since the
+// previous snapshot and closes those that are gone. A link reported with a
+// ConnectTime later than the start of its open connection dropped and
+// relinked in between: the old connection is closed when the new one began.
+func (l *LinkTracker) State(ev nng.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Clock().UTC()
+	current := make(map[linkKey]*store.Connection)
+	reported := make(map[linkKey]time.Time)
+	add := func(conn *store.Connection, at time.Time) {
+		key := keyOf(conn)
+		current[key] = conn
+		if !at.IsZero() {
+			reported[key] = at.UTC()
+		}
+	}
+	for _, c := range ev.Clients {
+		add(&store.Connection{
+			Kind:        store.KindClient,
+			Callsign:    strings.TrimSpace(c.Callsign),
+			Protocol:    c.Protocol,
+			Module:      strings.TrimSpace(c.OnModule),
+			ConnectedAt: connectTime(c.ConnectTime, now),
+		}, c.ConnectTime)
+	}
+	for _, p := range ev.Peers {
+		add(&store.Connection{
+			Kind:        store.KindPeer,
+			Callsign:    strings.TrimSpace(p.Callsign),
+			Protocol:    p.Protocol,
+			ConnectedAt: connectTime(p.ConnectTime, now),
+		}, p.ConnectTime)
+	}
+
+	for key, conn := range l.open {
+		if _, ok := current[key]; !ok {
+			l.close(key, conn, now)
+		}
+	}
+	for key, conn := range current {
+		if key.callsign == "" {
+			continue
+		}
+		old := l.open[key]
+		if old != nil {
+			at, ok := reported[key]
+			if !ok || !at.After(old.ConnectedAt.Add(relinkSlack)) {
+				continue
+			}
+			if at.After(now) {
+				at = now
+			}
+			l.close(key, old, at)
+		}
+		l.start(key, conn)
+	}
+}
+
+// Connect opens a client connection from a client_connect event.
+func (l *LinkTracker) Connect(ev nng.Event) {
+	conn := &store.Connection{
+		Kind:        store.KindClient,
+		Callsign:    strings.TrimSpace(ev.Callsign),
+		Protocol:    ev.Protocol,
+		Module:      strings.TrimSpace(ev.Module),
+		ConnectedAt: l.Clock().UTC(),
+	}
+	if conn.Callsign == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if key := keyOf(conn); l.open[key] == nil {
+		l.start(key, conn)
+	}
+}
+
+// Disconnect closes the client's connections from a client_disconnect
+// event, on every module unless the event names one.
+func (l *LinkTracker) Disconnect(ev nng.Event) {
+	call := strings.TrimSpace(ev.Callsign)
+	module := strings.TrimSpace(ev.Module)
+	if call == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.Clock().UTC()
+	for key, conn := range l.open {
+		if key.kind == store.KindClient && key.callsign == call && (module == "" || key.module == module) {
+			l.close(key, conn, now)
+		}
+	}
+}
+
+// Open returns a copy of the currently open connections.
+func (l *LinkTracker) Open() []store.Connection {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]store.Connection, 0, len(l.open))
+	for _, conn := range l.open {
+		out = append(out, *conn)
+	}
+	return out
+}
+
+// start persists and remembers a new connection. Callers must hold l.mu.
+func (l *LinkTracker) start(key linkKey, conn *store.Connection) {
+	conn.Reflector = l.Reflector
+	if err := l.sink.OpenConnection(conn); err != nil {
+		l.log.Error("Failed to save connection", zap.Error(err))
+	}
+	l.open[key] = conn
+	l.log.Info("Link connected",
+		zap.String("kind", conn.Kind),
+		zap.String("callsign", conn.Callsign),
+		zap.String("module", conn.Module))
+}
+
+// close persists the disconnect time and forgets the connection. Callers
+// must hold l.mu.
+func (l *LinkTracker) close(key linkKey, conn *store.Connection, at time.Time) {
+	if err := l.sink.CloseConnection(conn.ID, at); err != nil {
+		l.log.Error("Failed to close connection", zap.Error(err))
+	}
+	delete(l.open, key)
+	l.log.Info("Link disconnected",
+		zap.String("kind", conn.Kind),
+		zap.String("callsign", conn.Callsign),
+		zap.String("module", conn.Module))
+}
+
+// connectTime prefers the reflector's ConnectTime, falling back to now when
+// it is missing.
+func connectTime(t, now time.Time) time.Time {
+	if t.IsZero() {
+		return now
+	}
+	return t.UTC()
+}
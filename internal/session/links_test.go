@@ -0,0 +1,181 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+type fakeLinkSink struct {
+	nextID uint
+	conns  map[uint]*store.Connection
+}
+
+func (f *fakeLinkSink) OpenConnection(c *store.Connection) error {
+	f.nextID++
+	c.ID = f.nextID
+	cp := *c
+	f.conns[c.ID] = &cp
+	return nil
+}
+
+func (f *fakeLinkSink) CloseConnection(id uint, at time.Time) error {
+	f.conns[id].DisconnectedAt = &at
+	return nil
+}
+
+func (f *fakeLinkSink) find(kind, call, module string) []*store.Connection {
+	var out []*store.Connection
+	for id := uint(1); id <= f.nextID; id++ {
+		c := f.conns[id]
+		if c.Kind == kind && c.Callsign == call && c.Module == module {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestLinkTracker(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	linked := now.Add(-time.Hour)
+	f := &fakeLinkSink{conns: make(map[uint]*store.Connection)}
+	l := NewLinkTracker(f)
+	l.Clock = func() time.Time { return now }
+	l.Reflector = "URF002"
+
+	// First snapshot opens everything, keeping the reflector's ConnectTime
+	l.Handle(nng.Event{
+		Type:    "state",
+		Clients: []nng.Client{{Callsign: "KF8S ", OnModule: "A", Protocol: "DMR", ConnectTime: linked}},
+		Peers:   []nng.Peer{{Callsign: "XLX262", Protocol: "D-Extra", ConnectTime: linked}},
+	})
+	if n := len(l.Open()); n != 2 {
+		t.Fatalf("Expected 2 open connections, got %d", n)
+	}
+	if c := f.find(store.KindClient, "KF8S", "A"); len(c) != 1 || !c[0].ConnectedAt.Equal(linked) {
+		t.Fatalf("Unexpected client connection: %+v", c)
+	}
+
+	// Client moves to module B, peer drops
+	now = now.Add(10 * time.Second)
+	l.Handle(nng.Event{
+		Type:    "state",
+		Clients: []nng.Client{{Callsign: "KF8S", OnModule: "B", Protocol: "DMR"}},
+	})
+	if c := f.find(store.KindClient, "KF8S", "A"); c[0].DisconnectedAt == nil || !c[0].DisconnectedAt.Equal(now) {
+		t.Errorf("Expected module A connection closed at %v, got %+v", now, c[0])
+	}
+	if c := f.find(store.KindClient, "KF8S", "B"); len(c) != 1 || c[0].DisconnectedAt != nil || !c[0].ConnectedAt.Equal(now) {
+		t.Errorf("Expected open module B connection, got %+v", c)
+	}
+	if c := f.find(store.KindPeer, "XLX262", ""); c[0].DisconnectedAt == nil {
+		t.Errorf("Expected peer connection closed")
+	}
+
+	// Events between snapshots
+	now = now.Add(time.Second)
+	l.Handle(nng.Event{Type: "client_connect", Callsign: "N7TAE", Module: "C"})
+	l.Handle(nng.Event{Type: "client_disconnect", Callsign: "KF8S"})
+	if c := f.find(store.KindClient, "N7TAE", "C"); len(c) != 1 || c[0].DisconnectedAt != nil {
+		t.Errorf("Expected open connection from client_connect, got %+v", c)
+	}
+	if c := f.find(store.KindClient, "KF8S", "B"); c[0].DisconnectedAt == nil {
+		t.Errorf("Expected connection closed by client_disconnect")
+	}
+
+	// The next snapshot confirms N7TAE without opening a duplicate
+	l.Handle(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "N7TAE", OnModule: "C"}}})
+	if n := len(f.conns); n != 4 {
+		t.Errorf("Expected 4 connections in total, got %d", n)
+	}
+	if n := len(l.Open()); n != 1 {
+		t.Errorf("Expected 1 open connection, got %d", n)
+	}
+	for _, c := range f.conns {
+		if c.Reflector != "URF002" {
+			t.Errorf("Expected connection of %s tagged URF002, got %q", c.Callsign, c.Reflector)
+		}
+	}
+}
+
+func TestLinkTrackerLoad(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	f := &fakeLinkSink{nextID: 2, conns: map[uint]*store.Connection{
+		1: {ID: 1, Kind: store.KindClient, Callsign: "KF8S", Module: "A"},
+		2: {ID: 2, Kind: store.KindClient, Callsign: "W8CPT", Module: "B"},
+	}}
+	l := NewLinkTracker(f)
+	l.Clock = func() time.Time { return now }
+	l.Load([]store.Connection{*f.conns[1], *f.conns[2]})
+
+	// KF8S survived the restart, W8CPT left while the dashboard was down
+	l.State(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A"}}})
+	if f.conns[1].DisconnectedAt != nil {
+		t.Errorf("Expected surviving connection to stay open")
+	}
+	if f.conns[2].DisconnectedAt == nil {
+		t.Errorf("Expected stale connection to be closed")
+	}
+	if n := len(f.conns); n != 2 {
+		t.Errorf("Expected no new connections, got %d", n)
+	}
+
+	// A loaded connection the reflector reports as started since is not
+	// resumed
+	started := now.Add(-2 * time.Hour)
+	f.conns[3] = &store.Connection{ID: 3, Kind: store.KindPeer, Callsign: "XLX262", ConnectedAt: started}
+	f.nextID = 3
+	l = NewLinkTracker(f)
+	l.Clock = func() time.Time { return now }
+	l.Load([]store.Connection{*f.conns[1], *f.conns[3]})
+	relinked := now.Add(-time.Minute)
+	l.State(nng.Event{
+		Type:    "state",
+		Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A"}},
+		Peers:   []nng.Peer{{Callsign: "XLX262", ConnectTime: relinked}},
+	})
+	if at := f.conns[3].DisconnectedAt; at == nil || !at.Equal(relinked) {
+		t.Errorf("Expected the stale peer connection closed at %v, got %v", relinked, at)
+	}
+	if c := f.find(store.KindPeer, "XLX262", ""); len(c) != 2 || c[1].DisconnectedAt != nil {
+		t.Errorf("Expected a new open peer connection, got %+v", c)
+	}
+}
+
+func TestLinkTrackerRelink(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	linked := now.Add(-time.Hour)
+	f := &fakeLinkSink{conns: make(map[uint]*store.Connection)}
+	l := NewLinkTracker(f)
+	l.Clock = func() time.Time { return now }
+
+	l.State(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A", ConnectTime: linked}}})
+
+	// Same ConnectTime, or none at all, is the same connection
+	now = now.Add(10 * time.Second)
+	l.State(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A", ConnectTime: linked}}})
+	l.State(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A"}}})
+	if n := len(f.conns); n != 1 {
+		t.Fatalf("Expected 1 connection, got %d", n)
+	}
+
+	// KF8S dropped and relinked between two snapshots
+	relinked := now.Add(-3 * time.Second)
+	now = now.Add(10 * time.Second)
+	l.State(nng.Event{Type: "state", Clients: []nng.Client{{Callsign: "KF8S", OnModule: "A", ConnectTime: relinked}}})
+	c := f.find(store.KindClient, "KF8S", "A")
+	if len(c) != 2 {
+		t.Fatalf("Expected 2 connections after the relink, got %d", len(c))
+	}
+	if c[0].DisconnectedAt == nil || !c[0].DisconnectedAt.Equal(relinked) {
+		t.Errorf("Expected old connection closed at %v, got %v", relinked, c[0].DisconnectedAt)
+	}
+	if c[1].DisconnectedAt != nil || !c[1].ConnectedAt.Equal(relinked) {
+		t.Errorf("Expected new connection open since %v, got %+v", relinked, c[1])
+	}
+	if open := l.Open(); len(open) != 1 || open[0].ID != c[1].ID {
+		t.Errorf("Expected only the new connection open, got %+v", open)
+	}
+}
@@ -0,0 +1,359 @@
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+const (
+	// DefaultStateGrace is how long a session may be missing from the
+	// ActiveTalkers list of a state event before it is ended. It allows a
+	// late 'closing' event or state jitter to settle first.
+	DefaultStateGrace = 3 * time.Second
+
+	// DefaultTimeout ends a session that has not been seen at all, used as a
+	// safety net when state messages stop arriving.
+	DefaultTimeout = 30 * time.Second
+)
+
+// Sink receives the side effects of session tracking: persistence of
+// hearings and broadcasts of synthetic events to connected clients.
+type Sink interface {
+	store.HearingWriter
+	Broadcast(ev nng.Event)
+}
+
+// ActiveSession is a transmission that has been heard but not yet closed.
+type ActiveSession struct {
+	ID        uint
+	Callsign  string
+	Module    string
+	Protocol  string
+	Ur        string
+	Rpt2      string
+	StartTime time.Time
+	LastSeen  time.Time
+}
+
+// Tracker reconciles hearing, closing and state events from the reflector
+// into sessions with a start time and duration.
+type Tracker struct {
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+	// StateGrace and Timeout default to DefaultStateGrace and DefaultTimeout.
+	StateGrace time.Duration
+	Timeout    time.Duration
+	// Reflector tags the hearings and synthetic events of this tracker.
+	// Each reflector needs its own tracker.
+	Reflector string
+
+	sink     Sink
+	log      *zap.Logger
+	mu       sync.Mutex
+	sessions map[string]*ActiveSession
+}
+
+func NewTracker(sink Sink) *Tracker {
+	return &Tracker{
+		Clock:      time.Now,
+		StateGrace: DefaultStateGrace,
+		Timeout:    DefaultTimeout,
+		sink:       sink,
+		log:        zap.L(),
+		sessions:   make(map[string]*ActiveSession),
+	}
+}
+
+func (t *Tracker) now() time.Time {
+	return t.Clock().UTC()
+}
+
+func sessionKey(callsign, module string) string {
+	return callsign + ":" + module
+}
+
+// Handle dispatches an event to the matching input method and returns the
+// event as it should be broadcast to clients. It reports false for hearing
+// and closing events without a callsign, which should be dropped.
+func (t *Tracker) Handle(ev nng.Event) (nng.Event, bool) {
+	// Pre-process: Trim spaces
+	ev.My = strings.TrimSpace(ev.My)
+	ev.Module = strings.TrimSpace(ev.Module)
+
+	switch ev.Type {
+	case "hearing", "closing":
+		if ev.My == "" {
+			return ev, false
+		}
+		if ev.Type == "hearing" {
+			return t.Hearing(ev), true
+		}
+		return t.Closing(ev), true
+	case "state":
+		t.State(ev)
+	}
+	return ev, true
+}
+
+// Hearing starts a session for the talker or refreshes an existing one. The
+// returned event carries the session ID, protocol and start time.
+func (t *Tracker) Hearing(ev nng.Event) nng.Event {
+	if ev.My == "" {
+		return ev
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	sess, exists := t.sessions[sessionKey(ev.My, ev.Module)]
+	if !exists {
+		h := store.Hearing{
+			Reflector: t.Reflector,
+			My:        ev.My,
+			Ur:        ev.Ur,
+			Rpt1:      ev.Rpt1,
+			Rpt2:      ev.Rpt2,
+			Module:    ev.Module,
+			Protocol:  ev.Protocol,
+			CreatedAt: now,
+		}
+		if err := t.sink.CreateHearing(&h); err != nil {
+			t.log.Error("Failed to save hearing", zap.Error(err))
+		}
+		sess = &ActiveSession{
+			ID:        h.ID,
+			Callsign:  h.My,
+			Module:    h.Module,
+			Protocol:  h.Protocol,
+			Ur:        h.Ur,
+			Rpt2:      h.Rpt2,
+			StartTime: h.CreatedAt,
+			LastSeen:  now,
+		}
+		t.sessions[sessionKey(sess.Callsign, sess.Module)] = sess
+	} else {
+		sess.LastSeen = now
+	}
+	ev.ID = sess.ID
+	ev.Protocol = sess.Protocol
+	ev.CreatedAt = sess.StartTime.UTC()
+	ev.Status = "active"
+	return ev
+}
+
+// Closing ends the talker's session. If no session is open on the event's
+// module, any session for the same callsign is closed instead.
+func (t *Tracker) Closing(ev nng.Event) nng.Event {
+	if ev.My == "" {
+		return ev
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Better matching: Find session by callsign if exact key fails
+	sess, exists := t.sessions[sessionKey(ev.My, ev.Module)]
+	if !exists {
+		for _, s := range t.sessions {
+			if s.Callsign == ev.My {
+				sess = s
+				exists = true
+				break
+			}
+		}
+	}
+	if !exists {
+		return ev
+	}
+
+	duration := t.now().Sub(sess.StartTime).Seconds()
+	if err := t.sink.CloseHearing(sess.ID, duration); err != nil {
+		t.log.Error("Failed to update session duration", zap.Error(err))
+	}
+	ev.ID = sess.ID
+	ev.Status = "ended"
+	ev.Duration = duration
+	ev.CreatedAt = sess.StartTime.UTC()
+	ev.My = sess.Callsign
+	ev.Module = sess.Module
+	ev.Protocol = sess.Protocol
+	ev.Ur = sess.Ur
+	ev.Rpt2 = sess.Rpt2
+
+	// Clean up all sessions for this callsign to be safe
+	for k, s := range t.sessions {
+		if s.Callsign == sess.Callsign {
+			delete(t.sessions, k)
+		}
+	}
+	t.log.Info("Session closed via closing event", zap.Uint("id", sess.ID))
+	return ev
+}
+
+// State reconciles open sessions with the ActiveTalkers of a state event:
+// talkers still present get their module corrected and a synthetic
+// heartbeat, absent ones are ended after the grace period, and talkers
+// without a session are recovered.
+func (t *Tracker) State(ev nng.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	activeTalkersByCall := make(map[string]nng.ActiveTalker)
+	for _, talker := range ev.ActiveTalkers {
+		call := strings.TrimSpace(talker.Callsign)
+		if call != "" {
+			talker.Module = strings.TrimSpace(talker.Module)
+			activeTalkersByCall[call] = talker
+		}
+	}
+
+	now := t.now()
+
+	// A. Update/End existing sessions based on State
+	for key, sess := range t.sessions {
+		talker, ok := activeTalkersByCall[sess.Callsign]
+		if ok {
+			// User still talking. Correct module if needed.
+			if sess.Module != talker.Module {
+				t.log.Info("Correcting session module",
+					zap.String("callsign", sess.Callsign),
+					zap.String("old", sess.Module),
+					zap.String("new", talker.Module))
+				sess.Module = talker.Module
+				if err := t.sink.UpdateModule(sess.ID, sess.Module); err != nil {
+					t.log.Error("DB fix failed", zap.Error(err))
+				}
+				delete(t.sessions, key)
+				t.sessions[sessionKey(sess.Callsign, sess.Module)] = sess
+			}
+			sess.LastSeen = now
+			// Synthetic heartbeat
+			t.sink.Broadcast(nng.Event{
+				Type:      "hearing",
+				Status:    "active",
+				ID:        sess.ID,
+				Reflector: t.Reflector,
+				My:        sess.Callsign,
+				Ur:        sess.Ur,
+				Module:    sess.Module,
+				Rpt2:      sess.Rpt2,
+				Protocol:  sess.Protocol,
+				CreatedAt: sess.StartTime,
+			})
+		} else if now.Sub(sess.LastSeen) > t.StateGrace {
+			t.end(key, sess, now)
+			t.log.Info("Session ended via state sync", zap.Uint("id", sess.ID))
+		}
+	}
+
+	// B. Recovery: Start missing sessions from State
+	for call, talker := range activeTalkersByCall {
+		found := false
+		for _, sess := range t.sessions {
+			if sess.Callsign == call {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		h := store.Hearing{
+			Reflector: t.Reflector,
+			My:        call,
+			Module:    talker.Module,
+			Protocol:  talker.Protocol,
+			Ur:        "CQCQCQ",
+			Rpt1:      "SIMULATOR",
+			Rpt2:      "URFD " + talker.Module,
+			CreatedAt: now,
+		}
+		if err := t.sink.CreateHearing(&h); err != nil {
+			t.log.Error("Recovery failed", zap.Error(err))
+		}
+		t.sessions[sessionKey(call, talker.Module)] = &ActiveSession{
+			ID:        h.ID,
+			Callsign:  h.My,
+			Module:    h.Module,
+			Protocol:  h.Protocol,
+			Ur:        h.Ur,
+			Rpt2:      h.Rpt2,
+			StartTime: h.CreatedAt,
+			LastSeen:  now,
+		}
+		t.log.Info("Recovered session from State", zap.String("callsign", call))
+	}
+}
+
+// Tick ends sessions that have not been seen within Timeout. It is the
+// safety net for missing 'closing' and state events.
+func (t *Tracker) Tick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for key, sess := range t.sessions {
+		if now.Sub(sess.LastSeen) > t.Timeout {
+			t.end(key, sess, now)
+			t.log.Info("Session timed out (safety net)", zap.Uint("id", sess.ID))
+		}
+	}
+}
+
+// CloseAll ends every open session, writing its final duration and
+// broadcasting the ended event. It is used on shutdown.
+func (t *Tracker) CloseAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for key, sess := range t.sessions {
+		t.end(key, sess, now)
+		t.log.Info("Session closed on shutdown", zap.Uint("id", sess.ID))
+	}
+}
+
+// Sessions returns a copy of the currently open sessions.
+func (t *Tracker) Sessions() []ActiveSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]ActiveSession, 0, len(t.sessions))
+	for _, sess := range t.sessions {
+		out = append(out, *sess)
+	}
+	return out
+}
+
+// end writes the final duration, broadcasts the ended event and forgets the
+// session. Callers must hold t.mu.
+func (t *Tracker) end(key string, sess *ActiveSession, now time.Time) {
+	duration := now.Sub(sess.StartTime).Seconds()
+	if err := t.sink.CloseHearing(sess.ID, duration); err != nil {
+		t.log.Error("Failed to update session duration", zap.Error(err))
+	}
+	ev := endedEvent(sess, duration)
+	ev.Reflector = t.Reflector
+	t.sink.Broadcast(ev)
+	delete(t.sessions, key)
+}
+
+func endedEvent(sess *ActiveSession, duration float64) nng.Event {
+	return nng.Event{
+		Type:      "hearing",
+		Status:    "ended",
+		ID:        sess.ID,
+		My:        sess.Callsign,
+		Module:    sess.Module,
+		Protocol:  sess.Protocol,
+		Ur:        sess.Ur,
+		Rpt2:      sess.Rpt2,
+		Duration:  duration,
+		CreatedAt: sess.StartTime.UTC(),
+	}
+}
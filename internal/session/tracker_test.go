@@ -0,0 +1,300 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+	"github.com/dbehnke/urfd-nng-dashboard/internal/store"
+)
+
+type fakeSink struct {
+	nextID     uint
+	hearings   map[uint]*store.Hearing
+	broadcasts []nng.Event
+}
+
+func newFakeSink() *fakeSink {
+	return &fakeSink{hearings: make(map[uint]*store.Hearing)}
+}
+
+func (f *fakeSink) CreateHearing(h *store.Hearing) error {
+	f.nextID++
+	h.ID = f.nextID
+	c := *h
+	f.hearings[h.ID] = &c
+	return nil
+}
+
+func (f *fakeSink) UpdateModule(id uint, module string) error {
+	f.hearings[id].Module = module
+	return nil
+}
+
+func (f *fakeSink) CloseHearing(id uint, duration float64) error {
+	f.hearings[id].Duration = duration
+	return nil
+}
+
+func (f *fakeSink) Broadcast(ev nng.Event) {
+	f.broadcasts = append(f.broadcasts, ev)
+}
+
+func (f *fakeSink) ended() []nng.Event {
+	var out []nng.Event
+	for _, ev := range f.broadcasts {
+		if ev.Status == "ended" {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// step is one input to the tracker, applied after advancing the clock.
+type step struct {
+	advance time.Duration
+	ev      *nng.Event
+	tick    bool
+}
+
+func hearing(call, module string) *nng.Event {
+	return &nng.Event{Type: "hearing", My: call, Module: module, Protocol: "M17"}
+}
+
+func closing(call, module string) *nng.Event {
+	return &nng.Event{Type: "closing", My: call, Module: module}
+}
+
+func state(talkers ...nng.ActiveTalker) *nng.Event {
+	return &nng.Event{Type: "state", ActiveTalkers: talkers}
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []step
+		open     int
+		hearings int
+		validate func(t *testing.T, f *fakeSink, tr *Tracker)
+	}{
+		{
+			name: "Hearing and Closing",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: 2 * time.Second, ev: hearing("G4XYZ", "A")},
+				{advance: 3 * time.Second, ev: closing("G4XYZ", "A")},
+			},
+			open:     0,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				if d := f.hearings[1].Duration; d != 5 {
+					t.Errorf("Expected duration 5, got %v", d)
+				}
+			},
+		},
+		{
+			name: "Closing on Other Module",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: time.Second, ev: closing("G4XYZ", "B")},
+			},
+			open:     0,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				if d := f.hearings[1].Duration; d != 1 {
+					t.Errorf("Expected duration 1, got %v", d)
+				}
+			},
+		},
+		{
+			name: "Module Correction",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: time.Second, ev: state(nng.ActiveTalker{Callsign: "G4XYZ", Module: "C "})},
+				{advance: time.Second, ev: hearing("G4XYZ", "C")},
+			},
+			open:     1,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				if m := f.hearings[1].Module; m != "C" {
+					t.Errorf("Expected module C, got %q", m)
+				}
+				if m := tr.Sessions()[0].Module; m != "C" {
+					t.Errorf("Expected session on module C, got %q", m)
+				}
+			},
+		},
+		{
+			name: "Recovery from ActiveTalkers",
+			steps: []step{
+				{ev: state(nng.ActiveTalker{Callsign: "N7TAE", Module: "B", Protocol: "DMR"})},
+				{advance: time.Second, ev: hearing("N7TAE", "B")},
+			},
+			open:     1,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				h := f.hearings[1]
+				if h.My != "N7TAE" || h.Module != "B" || h.Protocol != "DMR" {
+					t.Errorf("Unexpected recovered hearing: %+v", h)
+				}
+			},
+		},
+		{
+			name: "State Grace Period",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: 2 * time.Second, ev: state()},
+			},
+			open:     1,
+			hearings: 1,
+		},
+		{
+			name: "Ended by State after Grace",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: 4 * time.Second, ev: state()},
+			},
+			open:     0,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				ended := f.ended()
+				if len(ended) != 1 || ended[0].ID != 1 || ended[0].Duration != 4 {
+					t.Errorf("Expected one ended event with duration 4, got %+v", ended)
+				}
+			},
+		},
+		{
+			name: "Heartbeat Keeps Session Open",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: 20 * time.Second, ev: state(nng.ActiveTalker{Callsign: "G4XYZ", Module: "A"})},
+				{advance: 20 * time.Second, tick: true},
+			},
+			open:     1,
+			hearings: 1,
+		},
+		{
+			name: "Safety Net Timeout",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{advance: 30 * time.Second, tick: true},
+				{advance: time.Second, tick: true},
+			},
+			open:     0,
+			hearings: 1,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				if d := f.hearings[1].Duration; d != 31 {
+					t.Errorf("Expected duration 31, got %v", d)
+				}
+			},
+		},
+		{
+			name: "Closed on Shutdown",
+			steps: []step{
+				{ev: hearing("G4XYZ", "A")},
+				{ev: hearing("N7TAE", "B")},
+				{advance: 2 * time.Second},
+			},
+			open:     2,
+			hearings: 2,
+			validate: func(t *testing.T, f *fakeSink, tr *Tracker) {
+				tr.CloseAll()
+				if n := len(tr.Sessions()); n != 0 {
+					t.Errorf("Expected no open sessions after CloseAll, got %d", n)
+				}
+				if n := len(f.ended()); n != 2 {
+					t.Errorf("Expected 2 ended events, got %d", n)
+				}
+				for id, h := range f.hearings {
+					if h.Duration != 2 {
+						t.Errorf("Expected duration 2 for hearing %d, got %v", id, h.Duration)
+					}
+				}
+			},
+		},
+		{
+			name: "Empty Callsign Dropped",
+			steps: []step{
+				{ev: hearing("  ", "A")},
+			},
+			open:     0,
+			hearings: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+			f := newFakeSink()
+			tr := NewTracker(f)
+			tr.Clock = func() time.Time { return now }
+
+			for _, s := range tt.steps {
+				now = now.Add(s.advance)
+				if s.ev != nil {
+					tr.Handle(*s.ev)
+				}
+				if s.tick {
+					tr.Tick()
+				}
+			}
+
+			if n := len(tr.Sessions()); n != tt.open {
+				t.Errorf("Expected %d open sessions, got %d", tt.open, n)
+			}
+			if n := len(f.hearings); n != tt.hearings {
+				t.Errorf("Expected %d hearings, got %d", tt.hearings, n)
+			}
+			if tt.validate != nil {
+				tt.validate(t, f, tr)
+			}
+		})
+	}
+}
+
+func TestHandleEnrichesEvents(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	tr := NewTracker(newFakeSink())
+	tr.Clock = func() time.Time { return now }
+
+	ev, ok := tr.Handle(nng.Event{Type: "hearing", My: " G4XYZ ", Module: "A", Protocol: "M17"})
+	if !ok || ev.ID != 1 || ev.Status != "active" || ev.My != "G4XYZ" || !ev.CreatedAt.Equal(now) {
+		t.Fatalf("Unexpected hearing event: %+v", ev)
+	}
+
+	now = now.Add(7 * time.Second)
+	ev, ok = tr.Handle(nng.Event{Type: "closing", My: "G4XYZ", Module: "A"})
+	if !ok || ev.ID != 1 || ev.Status != "ended" || ev.Duration != 7 || ev.Protocol != "M17" {
+		t.Fatalf("Unexpected closing event: %+v", ev)
+	}
+
+	if _, ok := tr.Handle(nng.Event{Type: "closing"}); ok {
+		t.Errorf("Expected closing without callsign to be dropped")
+	}
+}
+
+func TestTrackerReflector(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	sink := newFakeSink()
+	tr := NewTracker(sink)
+	tr.Clock = func() time.Time { return now }
+	tr.Reflector = "URF002"
+
+	tr.Handle(*hearing("G4XYZ", "A"))
+	tr.Handle(*state(nng.ActiveTalker{Callsign: "KF8S", Module: "B"}))
+	tr.CloseAll()
+
+	if len(sink.hearings) != 2 {
+		t.Fatalf("Expected 2 hearings, got %d", len(sink.hearings))
+	}
+	for _, h := range sink.hearings {
+		if h.Reflector != "URF002" {
+			t.Errorf("Expected hearing of %s tagged URF002, got %q", h.My, h.Reflector)
+		}
+	}
+	for _, ev := range sink.broadcasts {
+		if ev.Reflector != "URF002" {
+			t.Errorf("Expected %s %s broadcast tagged URF002, got %q", ev.Status, ev.My, ev.Reflector)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+)
+
+// Delta lists the changes to one list of a state snapshot. Added and
+// Updated carry whole entries; Removed carries the keys of entries that
+// are gone (see the Key functions).
+type Delta[T any] struct {
+	Added   []T      `json:"added,omitempty"`
+	Updated []T      `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+func (d *Delta[T]) empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// Diff is broadcast as a "state_diff" message in place of a full state
+// event. Seq increases by one per diff; a client that sees a gap should
+// fetch a fresh snapshot.
+type Diff struct {
+	Type          string                   `json:"type"`
+	Reflector     string                   `json:"reflector,omitempty"`
+	Seq           uint64                   `json:"seq"`
+	Clients       *Delta[nng.Client]       `json:"clients,omitempty"`
+	Users         *Delta[nng.User]         `json:"users,omitempty"`
+	Peers         *Delta[nng.Peer]         `json:"peers,omitempty"`
+	Modules       *Delta[nng.Module]       `json:"modules,omitempty"`
+	ActiveTalkers *Delta[nng.ActiveTalker] `json:"active_talkers,omitempty"`
+	// Configure is the whole new configuration, set only when it changed.
+	Configure json.RawMessage `json:"configure,omitempty"`
+}
+
+// Empty reports whether the snapshots were identical.
+func (d Diff) Empty() bool {
+	return d.Clients == nil && d.Users == nil && d.Peers == nil && d.Modules == nil && d.ActiveTalkers == nil && d.Configure == nil
+}
+
+// Keys identifying entries across snapshots. Clients may be linked to
+// several modules and users heard via several repeaters.
+func ClientKey(c nng.Client) string             { return c.Callsign + "|" + c.OnModule }
+func UserKey(u nng.User) string                 { return u.Callsign + "|" + u.Repeater }
+func PeerKey(p nng.Peer) string                 { return p.Callsign }
+func ModuleKey(m nng.Module) string             { return m.Name }
+func ActiveTalkerKey(t nng.ActiveTalker) string { return t.Callsign }
+
+// Compute returns the changes from prev to next. Seq is left zero.
+func Compute(prev, next nng.Event) Diff {
+	return Diff{
+		Type:          "state_diff",
+		Reflector:     next.Reflector,
+		Clients:       diffList(prev.Clients, next.Clients, ClientKey),
+		Users:         diffList(prev.Users, next.Users, UserKey),
+		Peers:         diffList(prev.Peers, next.Peers, PeerKey),
+		Modules:       diffList(prev.Modules, next.Modules, ModuleKey),
+		ActiveTalkers: diffList(prev.ActiveTalkers, next.ActiveTalkers, ActiveTalkerKey),
+		Configure:     diffConfigure(prev.Configure, next.Configure),
+	}
+}
+
+// diffConfigure returns next when the configuration changed, or nil. A
+// configuration that went away is sent as an empty object.
+func diffConfigure(prev, next json.RawMessage) json.RawMessage {
+	if compact(prev) == compact(next) {
+		return nil
+	}
+	if len(next) == 0 {
+		return json.RawMessage("{}")
+	}
+	return next
+}
+
+// compact strips insignificant whitespace, so only real changes count.
+func compact(data json.RawMessage) string {
+	var b bytes.Buffer
+	if json.Compact(&b, data) != nil {
+		return string(data)
+	}
+	return b.String()
+}
+
+// diffList compares two lists by key, returning nil when nothing changed.
+// Entries are compared by their JSON encoding, which is what clients see.
+func diffList[T any](prev, next []T, key func(T) string) *Delta[T] {
+	old := make(map[string][]byte, len(prev))
+	for _, v := range prev {
+		old[key(v)], _ = json.Marshal(v)
+	}
+
+	d := &Delta[T]{}
+	seen := make(map[string]bool, len(next))
+	for _, v := range next {
+		k := key(v)
+		seen[k] = true
+		prevData, existed := old[k]
+		if !existed {
+			d.Added = append(d.Added, v)
+			continue
+		}
+		if data, _ := json.Marshal(v); !bytes.Equal(prevData, data) {
+			d.Updated = append(d.Updated, v)
+		}
+	}
+	for _, v := range prev {
+		if k := key(v); !seen[k] {
+			seen[k] = true
+			d.Removed = append(d.Removed, k)
+		}
+	}
+
+	if d.empty() {
+		return nil
+	}
+	return d
+}
+
+// Cache holds the last state snapshot and numbers the diffs between
+// successive snapshots. Each reflector needs its own Cache.
+type Cache struct {
+	mu   sync.RWMutex
+	last nng.Event
+	seq  uint64
+}
+
+// Update stores ev as the latest snapshot and returns the diff from the
+// previous one, or false when nothing changed. The first snapshot is
+// diffed against an empty state, so it arrives as Seq 1 with everything
+// added.
+func (c *Cache) Update(ev nng.Event) (Diff, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	diff := Compute(c.last, ev)
+	c.last = ev
+	if diff.Empty() {
+		c.last.Seq = c.seq
+		return diff, false
+	}
+	c.seq++
+	c.last.Seq = c.seq
+	diff.Seq = c.seq
+	return diff, true
+}
+
+// Snapshot returns the last state event, stamped with the Seq of the last
+// diff it includes. Its Type is empty before the first state arrives.
+func (c *Cache) Snapshot() nng.Event {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.last
+}
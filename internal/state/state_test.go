@@ -0,0 +1,104 @@
+package state
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/nng"
+)
+
+func TestCompute(t *testing.T) {
+	connected := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	prev := nng.Event{
+		Type: "state",
+		Clients: []nng.Client{
+			{Callsign: "KF8S", OnModule: "A", ConnectTime: connected},
+			{Callsign: "W8CPT", OnModule: "B", ConnectTime: connected},
+		},
+		Users:   []nng.User{{Callsign: "N7TAE", OnModule: "A", LastHeard: connected}},
+		Peers:   []nng.Peer{{Callsign: "XLX262"}},
+		Modules: []nng.Module{{Name: "A"}, {Name: "B"}},
+	}
+	next := nng.Event{
+		Type:      "state",
+		Reflector: "URF270",
+		Clients: []nng.Client{
+			{Callsign: "KF8S", OnModule: "A", ConnectTime: connected},
+			{Callsign: "KE8VSI", OnModule: "C", ConnectTime: connected},
+		},
+		Users:   []nng.User{{Callsign: "N7TAE", OnModule: "A", LastHeard: connected.Add(time.Minute)}},
+		Peers:   []nng.Peer{{Callsign: "XLX262"}},
+		Modules: []nng.Module{{Name: "A"}, {Name: "B"}},
+	}
+
+	d := Compute(prev, next)
+	if d.Type != "state_diff" || d.Reflector != "URF270" {
+		t.Errorf("Expected a state_diff of URF270, got %s of %q", d.Type, d.Reflector)
+	}
+	if d.Clients == nil || len(d.Clients.Added) != 1 || d.Clients.Added[0].Callsign != "KE8VSI" {
+		t.Errorf("Unexpected client additions: %+v", d.Clients)
+	}
+	if len(d.Clients.Removed) != 1 || d.Clients.Removed[0] != "W8CPT|B" {
+		t.Errorf("Unexpected client removals: %+v", d.Clients.Removed)
+	}
+	if len(d.Clients.Updated) != 0 {
+		t.Errorf("Expected no client updates, got %+v", d.Clients.Updated)
+	}
+	if d.Users == nil || len(d.Users.Updated) != 1 || len(d.Users.Added) != 0 {
+		t.Errorf("Unexpected user changes: %+v", d.Users)
+	}
+	if d.Peers != nil || d.Modules != nil || d.ActiveTalkers != nil {
+		t.Errorf("Expected unchanged lists to be omitted: %+v", d)
+	}
+	if d.Configure != nil {
+		t.Errorf("Expected no configuration change, got %s", d.Configure)
+	}
+	if !Compute(next, next).Empty() {
+		t.Errorf("Expected identical snapshots to produce an empty diff")
+	}
+
+	// Configuration changes are sent whole, ignoring formatting
+	configured := next
+	configured.Configure = json.RawMessage(`{"Modules": "ABC"}`)
+	d = Compute(next, configured)
+	if d.Empty() || string(d.Configure) != `{"Modules": "ABC"}` {
+		t.Errorf("Expected the new configuration, got %s", d.Configure)
+	}
+	reformatted := next
+	reformatted.Configure = json.RawMessage(`{"Modules":"ABC"}`)
+	if d := Compute(configured, reformatted); !d.Empty() {
+		t.Errorf("Expected reformatting to be no change, got %s", d.Configure)
+	}
+	if d := Compute(configured, next); string(d.Configure) != "{}" {
+		t.Errorf("Expected a removed configuration to be sent empty, got %s", d.Configure)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var c Cache
+	if c.Snapshot().Type != "" {
+		t.Fatalf("Expected empty snapshot before first state")
+	}
+
+	first := nng.Event{Type: "state", Peers: []nng.Peer{{Callsign: "XLX262"}}}
+	d, changed := c.Update(first)
+	if !changed || d.Seq != 1 || len(d.Peers.Added) != 1 {
+		t.Fatalf("Expected first snapshot as diff 1 adding everything, got %+v", d)
+	}
+
+	if _, changed := c.Update(first); changed {
+		t.Errorf("Expected no diff for an unchanged snapshot")
+	}
+	if s := c.Snapshot(); s.Seq != 1 || len(s.Peers) != 1 {
+		t.Errorf("Unexpected snapshot: %+v", s)
+	}
+
+	d, changed = c.Update(nng.Event{Type: "state"})
+	if !changed || d.Seq != 2 || len(d.Peers.Removed) != 1 {
+		t.Errorf("Expected diff 2 removing the peer, got %+v", d)
+	}
+	if s := c.Snapshot(); s.Seq != 2 || len(s.Peers) != 0 {
+		t.Errorf("Unexpected snapshot: %+v", s)
+	}
+}
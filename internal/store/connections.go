@@ -0,0 +1,72 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// ConnectionFilter selects connections. Zero values match everything.
+type ConnectionFilter struct {
+	Kind      string
+	Reflector string
+	// Callsign is exact or a pattern with '*' and '?' wildcards
+	Callsign string
+	Module   string
+	// Since and Until select connections that overlapped the range
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// ListConnections returns the connections matching f, most recently
+// connected first.
+func (s *Store) ListConnections(f ConnectionFilter) ([]Connection, error) {
+	q := s.DB.Model(&Connection{})
+	if f.Kind != "" {
+		q = q.Where("kind = ?", f.Kind)
+	}
+	if f.Reflector != "" {
+		q = q.Where("reflector = ?", f.Reflector)
+	}
+	if f.Callsign != "" {
+		call := strings.ToUpper(f.Callsign)
+		if strings.ContainsAny(call, "*?") {
+			q = q.Where(`callsign LIKE ? ESCAPE '\'`, likePattern(call))
+		} else {
+			q = q.Where("callsign = ?", call)
+		}
+	}
+	if f.Module != "" {
+		q = q.Where("module = ?", f.Module)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("disconnected_at IS NULL OR disconnected_at >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("connected_at <= ?", f.Until.UTC())
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	conns := []Connection{}
+	err := q.Order("connected_at desc, id desc").Find(&conns).Error
+	return conns, err
+}
+
+// OpenConnections returns the connections without a disconnect time.
+func (s *Store) OpenConnections() ([]Connection, error) {
+	var conns []Connection
+	err := s.DB.Where("disconnected_at IS NULL").Find(&conns).Error
+	return conns, err
+}
+
+// OpenConnection inserts c, setting its ID.
+func (s *Store) OpenConnection(c *Connection) error {
+	return s.DB.Create(c).Error
+}
+
+// CloseConnection records when the connection with id ended.
+func (s *Store) CloseConnection(id uint, at time.Time) error {
+	return s.DB.Model(&Connection{}).Where("id = ?", id).Update("disconnected_at", at).Error
+}
@@ -0,0 +1,198 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a HearingRepository held in memory. It applies filters with
+// the same semantics as Store and is meant for tests.
+type Memory struct {
+	mu       sync.RWMutex
+	hearings []Hearing // in ID order
+	nextID   uint
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) CreateHearing(h *Hearing) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	h.ID = m.nextID
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+	m.hearings = append(m.hearings, *h)
+	return nil
+}
+
+func (m *Memory) UpdateModule(id uint, module string) error {
+	return m.update(id, func(h *Hearing) { h.Module = module })
+}
+
+func (m *Memory) CloseHearing(id uint, duration float64) error {
+	return m.update(id, func(h *Hearing) { h.Duration = duration })
+}
+
+// update applies fn to the hearing with id. Like an UPDATE matching no
+// rows, a missing hearing is not an error.
+func (m *Memory) update(id uint, fn func(*Hearing)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := sort.Search(len(m.hearings), func(i int) bool { return m.hearings[i].ID >= id })
+	if i < len(m.hearings) && m.hearings[i].ID == id {
+		fn(&m.hearings[i])
+	}
+	return nil
+}
+
+// matching returns the hearings matching f, oldest first, ignoring its
+// page.
+func (m *Memory) matching(f HearingFilter) ([]Hearing, error) {
+	match, err := f.matcher()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []Hearing
+	for _, h := range m.hearings {
+		if match(h) {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) ListHearings(f HearingFilter) ([]Hearing, error) {
+	all, err := m.matching(f)
+	if err != nil {
+		return nil, err
+	}
+	hearings := []Hearing{}
+	for i := len(all) - 1; i >= 0; i-- {
+		if f.BeforeID > 0 && all[i].ID >= f.BeforeID {
+			continue
+		}
+		if f.Limit > 0 && len(hearings) == f.Limit {
+			break
+		}
+		hearings = append(hearings, all[i])
+	}
+	return hearings, nil
+}
+
+func (m *Memory) CountHearings(f HearingFilter) (int64, error) {
+	all, err := m.matching(f)
+	return int64(len(all)), err
+}
+
+func (m *Memory) EachHearing(f HearingFilter, fn func(Hearing) error) error {
+	all, err := m.matching(f)
+	if err != nil {
+		return err
+	}
+	for _, h := range all {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CallsignActivity(call string) (CallsignActivity, error) {
+	a := CallsignActivity{Modules: []string{}, Protocols: []string{}}
+	// The callsign is matched exactly, as Store does, without wildcards
+	m.mu.RLock()
+	var hearings []Hearing
+	for _, h := range m.hearings {
+		if h.My == call {
+			hearings = append(hearings, h)
+		}
+	}
+	m.mu.RUnlock()
+	if len(hearings) == 0 {
+		return a, nil
+	}
+	sort.SliceStable(hearings, func(i, j int) bool { return hearings[i].CreatedAt.Before(hearings[j].CreatedAt) })
+
+	modules := make(map[string]bool)
+	protocols := make(map[string]bool)
+	for _, h := range hearings {
+		a.Transmissions++
+		a.TalkTime += h.Duration
+		a.Hours[h.CreatedAt.UTC().Hour()]++
+		if !modules[h.Module] {
+			modules[h.Module] = true
+			a.Modules = append(a.Modules, h.Module)
+		}
+		if !protocols[h.Protocol] {
+			protocols[h.Protocol] = true
+			a.Protocols = append(a.Protocols, h.Protocol)
+		}
+	}
+	a.FirstHeard = hearings[0].CreatedAt.UTC()
+	a.LastHeard = hearings[len(hearings)-1].CreatedAt.UTC()
+	sort.Strings(a.Modules)
+	sort.Strings(a.Protocols)
+	return a, nil
+}
+
+func (m *Memory) ModuleActivity(f HearingFilter, bucket Bucket) ([]ModuleActivity, error) {
+	hearings, err := m.matching(f)
+	if err != nil {
+		return nil, err
+	}
+	agg := newModuleAggregator(bucket)
+	for _, h := range hearings {
+		agg.add(h)
+	}
+	return agg.result(), nil
+}
+
+// matcher returns a predicate applying the conditions of f the way where
+// does in SQL.
+func (f HearingFilter) matcher() (func(Hearing) bool, error) {
+	call := strings.ToUpper(f.My)
+	var pattern *regexp.Regexp
+	if strings.ContainsAny(call, "*?") {
+		expr := regexp.QuoteMeta(call)
+		expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+		var err error
+		if pattern, err = regexp.Compile("^" + expr + "$"); err != nil {
+			return nil, fmt.Errorf("invalid callsign pattern %q: %w", f.My, err)
+		}
+	}
+
+	return func(h Hearing) bool {
+		switch {
+		case pattern != nil && !pattern.MatchString(h.My):
+			return false
+		case pattern == nil && call != "" && h.My != call:
+			return false
+		case f.Reflector != "" && h.Reflector != f.Reflector:
+			return false
+		case f.Module != "" && h.Module != f.Module:
+			return false
+		case f.Protocol != "" && h.Protocol != f.Protocol:
+			return false
+		case !f.Since.IsZero() && h.CreatedAt.Before(f.Since):
+			return false
+		case !f.Until.IsZero() && !h.CreatedAt.Before(f.Until):
+			return false
+		case f.MinDuration > 0 && h.Duration < f.MinDuration:
+			return false
+		}
+		return true
+	}, nil
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations are embedded per SQL dialect, named NNNN_description.sql and
+// applied in version order. Applied migrations must never be edited; add a
+// new one instead.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+// Migration is one forward schema change.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus is a known migration and when it was applied, if it has
+// been.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaVersion records an applied migration.
+type schemaVersion struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaVersion) TableName() string { return "schema_version" }
+
+// migrateLock is the PostgreSQL advisory lock held while migrating, so
+// dashboards sharing a database and starting together apply each migration
+// once.
+const migrateLock = 0x75726664 // "urfd"
+
+const createSchemaVersion = `CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`
+
+// migrations returns the embedded migrations for dialect in version order.
+func migrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	var out []Migration
+	seen := make(map[int]string)
+	for _, e := range entries {
+		m := migrationName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		if prev, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", prev, e.Name(), version)
+		}
+		seen[version] = e.Name()
+
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Migration{Version: version, Name: m[2], SQL: string(data)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// statements splits a migration into the statements ending each line with
+// ';', since not every driver accepts several in one Exec. Comment-only
+// pieces are skipped.
+func statements(sql string) []string {
+	var out []string
+	var stmt strings.Builder
+	code := false
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+			code = true
+		}
+		stmt.WriteString(line)
+		stmt.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			if code {
+				out = append(out, strings.TrimSpace(stmt.String()))
+			}
+			stmt.Reset()
+			code = false
+		}
+	}
+	if code {
+		out = append(out, strings.TrimSpace(stmt.String()))
+	}
+	return out
+}
+
+// MigrationStatus lists every known migration, oldest first, with the time
+// it was applied to this database.
+func (s *Store) MigrationStatus() ([]MigrationStatus, error) {
+	return migrationStatus(s.DB)
+}
+
+func migrationStatus(db *gorm.DB) ([]MigrationStatus, error) {
+	all, err := migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec(createSchemaVersion).Error; err != nil {
+		return nil, err
+	}
+	var applied []schemaVersion
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	at := make(map[int]time.Time, len(applied))
+	for _, v := range applied {
+		at[v.Version] = v.AppliedAt
+	}
+
+	out := make([]MigrationStatus, len(all))
+	for i, m := range all {
+		out[i].Migration = m
+		if t, ok := at[m.Version]; ok {
+			out[i].AppliedAt = &t
+		}
+	}
+	return out, nil
+}
+
+// Migrate applies the pending migrations in order, each in its own
+// transaction, and returns those it applied. On PostgreSQL it holds an
+// advisory lock from reading the schema version until it is done, so a
+// concurrent Migrate waits and then finds nothing left to apply.
+func (s *Store) Migrate() ([]Migration, error) {
+	if s.DB.Dialector.Name() != DriverPostgres {
+		return migrate(s.DB)
+	}
+	var applied []Migration
+	err := s.DB.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrateLock).Error; err != nil {
+			return fmt.Errorf("lock migrations: %w", err)
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", migrateLock)
+		var err error
+		applied, err = migrate(conn)
+		return err
+	})
+	return applied, err
+}
+
+func migrate(db *gorm.DB) ([]Migration, error) {
+	status, err := migrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, st := range status {
+		if st.AppliedAt != nil {
+			continue
+		}
+		m := st.Migration
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range statements(m.SQL) {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Create(&schemaVersion{Version: m.Version, Name: m.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
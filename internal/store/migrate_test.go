@@ -0,0 +1,173 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	all, err := migrations("sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d (%s)", i, i+1, m.Version, m.Name)
+		}
+	}
+
+	tests := []struct {
+		name string
+		// setup prepares the database before migrating
+		setup func(s *Store) error
+	}{
+		{name: "Fresh", setup: func(*Store) error { return nil }},
+		{
+			// A database created by AutoMigrate before migrations existed
+			name: "Adopt Legacy",
+			setup: func(s *Store) error {
+				if err := s.DB.Exec("CREATE TABLE `hearings` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`my` text,`ur` text,`rpt1` text,`rpt2` text,`module` text,`protocol` text,`duration` real)").Error; err != nil {
+					return err
+				}
+				if err := s.DB.Exec("CREATE INDEX `idx_hearings_my` ON `hearings`(`my`)").Error; err != nil {
+					return err
+				}
+				return s.DB.Exec("INSERT INTO `hearings` (`my`, `module`) VALUES ('KF8S', 'A')").Error
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer func() { _ = s.Close() }()
+			if err := tt.setup(s); err != nil {
+				t.Fatalf("Setup failed: %v", err)
+			}
+
+			applied, err := s.Migrate()
+			if err != nil {
+				t.Fatalf("Migrate failed: %v", err)
+			}
+			if len(applied) != len(all) {
+				t.Errorf("Expected %d migrations applied, got %d", len(all), len(applied))
+			}
+			if again, err := s.Migrate(); err != nil || len(again) != 0 {
+				t.Errorf("Expected nothing left to apply, got %d (%v)", len(again), err)
+			}
+
+			status, err := s.MigrationStatus()
+			if err != nil {
+				t.Fatalf("MigrationStatus failed: %v", err)
+			}
+			for _, st := range status {
+				if st.AppliedAt == nil {
+					t.Errorf("Expected %04d_%s to be applied", st.Version, st.Name)
+				}
+			}
+
+			// The last statement of the last migration ran
+			var index string
+			s.DB.Raw("SELECT sql FROM sqlite_master WHERE name = ?", "idx_daily_aggregate").Scan(&index)
+			if !strings.Contains(index, "reflector") {
+				t.Errorf("Expected idx_daily_aggregate to include the reflector, got %q", index)
+			}
+			if err := s.DB.Create(&DailyAggregate{Reflector: "URF001", Callsign: "KF8S", Module: "A"}).Error; err != nil {
+				t.Errorf("Failed to use migrated table: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateReportsFailure(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+
+	// A conflicting object blocks the first migration
+	if err := s.DB.Exec("CREATE VIEW hearings AS SELECT 1 AS id").Error; err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if _, err := s.Migrate(); err == nil {
+		t.Fatalf("Expected Migrate to fail")
+	}
+	status, err := s.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus failed: %v", err)
+	}
+	if status[0].AppliedAt != nil {
+		t.Errorf("Expected failed migration to stay pending")
+	}
+}
+
+// TestMigrateConcurrent starts several dashboards on one empty PostgreSQL
+// database at once; each migration must be applied exactly once.
+func TestMigrateConcurrent(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", postgresDSNEnv)
+	}
+	all, err := migrations(DriverPostgres)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	dsn = newPostgresSchema(t, dsn)
+
+	const n = 4
+	counts := make([]int, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		s, err := Connect(Config{Driver: DriverPostgres, DSN: dsn})
+		if err != nil {
+			t.Fatalf("Failed to connect: %v", err)
+		}
+		defer func() { _ = s.Close() }()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			applied, err := s.Migrate()
+			counts[i], errs[i] = len(applied), err
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range n {
+		if errs[i] != nil {
+			t.Errorf("Migrate %d failed: %v", i, errs[i])
+		}
+		total += counts[i]
+	}
+	if total != len(all) {
+		t.Errorf("Expected %d migrations applied in total, got %d", len(all), total)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	sql := `-- Leading comment
+CREATE TABLE a (
+	id INTEGER -- inline; not the end
+);
+CREATE INDEX i ON a (id);
+-- Trailing comment;
+`
+	got := statements(sql)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 statements, got %d: %q", len(got), got)
+	}
+	if !strings.HasSuffix(got[0], "id INTEGER -- inline; not the end\n);") {
+		t.Errorf("Unexpected first statement: %q", got[0])
+	}
+	if got[1] != "CREATE INDEX i ON a (id);" {
+		t.Errorf("Unexpected second statement: %q", got[1])
+	}
+}
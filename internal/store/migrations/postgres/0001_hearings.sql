@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS hearings (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	my TEXT,
+	ur TEXT,
+	rpt1 TEXT,
+	rpt2 TEXT,
+	module TEXT,
+	protocol TEXT,
+	duration DOUBLE PRECISION
+);
+CREATE INDEX IF NOT EXISTS idx_hearings_module ON hearings (module);
+CREATE INDEX IF NOT EXISTS idx_hearings_my ON hearings (my);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS connections (
+	id BIGSERIAL PRIMARY KEY,
+	kind TEXT,
+	callsign TEXT,
+	protocol TEXT,
+	module TEXT,
+	connected_at TIMESTAMPTZ,
+	disconnected_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_connections_connected_at ON connections (connected_at);
+CREATE INDEX IF NOT EXISTS idx_connections_callsign ON connections (callsign);
+CREATE INDEX IF NOT EXISTS idx_connections_kind ON connections (kind);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS daily_aggregates (
+	id BIGSERIAL PRIMARY KEY,
+	day TIMESTAMPTZ,
+	callsign TEXT,
+	module TEXT,
+	transmissions BIGINT,
+	talk_time DOUBLE PRECISION
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_aggregate ON daily_aggregates (day, callsign, module);
@@ -0,0 +1,6 @@
+-- Hearings and connections are tagged with the reflector they came from;
+-- rows from before multi-reflector support keep the empty ID
+ALTER TABLE hearings ADD COLUMN reflector TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_hearings_reflector ON hearings (reflector);
+ALTER TABLE connections ADD COLUMN reflector TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_connections_reflector ON connections (reflector);
@@ -0,0 +1,4 @@
+-- Daily aggregates keep the reflector of the hearings they summarise
+ALTER TABLE daily_aggregates ADD COLUMN reflector TEXT NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS idx_daily_aggregate;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_aggregate ON daily_aggregates (day, reflector, callsign, module);
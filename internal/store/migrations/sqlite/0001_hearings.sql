@@ -0,0 +1,4 @@
+-- Hearings as created by AutoMigrate in earlier releases, which this adopts
+CREATE TABLE IF NOT EXISTS `hearings` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`my` text,`ur` text,`rpt1` text,`rpt2` text,`module` text,`protocol` text,`duration` real);
+CREATE INDEX IF NOT EXISTS `idx_hearings_module` ON `hearings`(`module`);
+CREATE INDEX IF NOT EXISTS `idx_hearings_my` ON `hearings`(`my`);
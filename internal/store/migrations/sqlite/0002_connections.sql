@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS `connections` (`id` integer PRIMARY KEY AUTOINCREMENT,`kind` text,`callsign` text,`protocol` text,`module` text,`connected_at` datetime,`disconnected_at` datetime);
+CREATE INDEX IF NOT EXISTS `idx_connections_connected_at` ON `connections`(`connected_at`);
+CREATE INDEX IF NOT EXISTS `idx_connections_callsign` ON `connections`(`callsign`);
+CREATE INDEX IF NOT EXISTS `idx_connections_kind` ON `connections`(`kind`);
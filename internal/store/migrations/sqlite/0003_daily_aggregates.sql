@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS `daily_aggregates` (`id` integer PRIMARY KEY AUTOINCREMENT,`day` datetime,`callsign` text,`module` text,`transmissions` integer,`talk_time` real);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_daily_aggregate` ON `daily_aggregates`(`day`,`callsign`,`module`);
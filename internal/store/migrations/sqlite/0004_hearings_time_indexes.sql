@@ -0,0 +1,3 @@
+-- Time range filters, and a callsign's hearings in time order
+CREATE INDEX IF NOT EXISTS `idx_hearings_created_at` ON `hearings`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_hearings_my_created_at` ON `hearings`(`my`,`created_at`);
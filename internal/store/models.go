@@ -6,6 +6,8 @@ import "time"
 type Hearing struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time `json:"created_at"`
+	// Reflector is the ID of the reflector the hearing came from
+	Reflector string `json:"reflector,omitempty" gorm:"index"`
 
 	My       string `json:"my" gorm:"index"`
 	Ur       string `json:"ur"`
@@ -17,3 +19,41 @@ type Hearing struct {
 	// Duration of transmission (optional/computed later)
 	Duration float64 `json:"duration"`
 }
+
+// Connection kinds
+const (
+	KindClient = "client"
+	KindPeer   = "peer"
+)
+
+// Connection is a period during which a client (node) or peer was linked
+// to the reflector
+type Connection struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Kind string `json:"kind" gorm:"index"` // KindClient | KindPeer
+	// Reflector is the ID of the reflector the link was seen on
+	Reflector string `json:"reflector,omitempty" gorm:"index"`
+	Callsign  string `json:"callsign" gorm:"index"`
+	Protocol  string `json:"protocol"`
+	// Module the client was linked to; empty for peers
+	Module string `json:"module"`
+
+	ConnectedAt time.Time `json:"connected_at" gorm:"index"`
+	// DisconnectedAt is nil while still linked
+	DisconnectedAt *time.Time `json:"disconnected_at"`
+}
+
+// DailyAggregate summarises the pruned hearings of one callsign on one
+// module of one reflector for one UTC day, so long-term statistics survive
+// retention.
+type DailyAggregate struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Day       time.Time `json:"day" gorm:"uniqueIndex:idx_daily_aggregate"`
+	Reflector string    `json:"reflector,omitempty" gorm:"uniqueIndex:idx_daily_aggregate"`
+	Callsign  string    `json:"callsign" gorm:"uniqueIndex:idx_daily_aggregate"`
+	Module    string    `json:"module" gorm:"uniqueIndex:idx_daily_aggregate"`
+
+	Transmissions int64 `json:"transmissions"`
+	// TalkTime is the total duration in seconds
+	TalkTime float64 `json:"talk_time"`
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HearingFilter selects hearings. Zero values match everything.
+type HearingFilter struct {
+	// My is an exact callsign, or a pattern where '*' matches any run of
+	// characters and '?' a single character (e.g. "W8*").
+	My          string
+	Reflector   string
+	Module      string
+	Protocol    string
+	Since       time.Time
+	Until       time.Time
+	MinDuration float64
+
+	// BeforeID and Limit page through results newest first. They are
+	// ignored when counting.
+	BeforeID uint
+	Limit    int
+}
+
+// ListHearings returns the hearings matching f, newest first.
+func (s *Store) ListHearings(f HearingFilter) ([]Hearing, error) {
+	q := f.where(s.DB.Model(&Hearing{}))
+	if f.BeforeID > 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+
+	hearings := []Hearing{}
+	err := q.Order("id desc").Find(&hearings).Error
+	return hearings, err
+}
+
+// CountHearings returns the number of hearings matching f, regardless of
+// its page.
+func (s *Store) CountHearings(f HearingFilter) (int64, error) {
+	var count int64
+	err := f.where(s.DB.Model(&Hearing{})).Count(&count).Error
+	return count, err
+}
+
+// eachChunk is the number of rows EachHearing reads per query.
+const eachChunk = 500
+
+// EachHearing reads the matching hearings in chunks, so exports of any size
+// use bounded memory without holding a read transaction open.
+func (s *Store) EachHearing(f HearingFilter, fn func(Hearing) error) error {
+	var after uint
+	for {
+		var chunk []Hearing
+		err := f.where(s.DB.Model(&Hearing{})).
+			Where("id > ?", after).
+			Order("id").
+			Limit(eachChunk).
+			Find(&chunk).Error
+		if err != nil {
+			return err
+		}
+		for _, h := range chunk {
+			if err := fn(h); err != nil {
+				return err
+			}
+		}
+		if len(chunk) < eachChunk {
+			return nil
+		}
+		after = chunk[len(chunk)-1].ID
+	}
+}
+
+func (f HearingFilter) where(q *gorm.DB) *gorm.DB {
+	if f.My != "" {
+		call := strings.ToUpper(f.My)
+		if strings.ContainsAny(call, "*?") {
+			q = q.Where(`my LIKE ? ESCAPE '\'`, likePattern(call))
+		} else {
+			q = q.Where("my = ?", call)
+		}
+	}
+	if f.Reflector != "" {
+		q = q.Where("reflector = ?", f.Reflector)
+	}
+	if f.Module != "" {
+		q = q.Where("module = ?", f.Module)
+	}
+	if f.Protocol != "" {
+		q = q.Where("protocol = ?", f.Protocol)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until.UTC())
+	}
+	if f.MinDuration > 0 {
+		q = q.Where("duration >= ?", f.MinDuration)
+	}
+	return q
+}
+
+// likePattern translates '*' and '?' wildcards to a LIKE pattern, escaping
+// the characters LIKE treats specially.
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`, `?`, `_`)
+	return r.Replace(s)
+}
+
+// hourOfDay is the SQL expression for the UTC hour of a hearing's start.
+func (s *Store) hourOfDay() string {
+	if s.DB.Dialector.Name() == DriverPostgres {
+		return "CAST(EXTRACT(HOUR FROM created_at AT TIME ZONE 'UTC') AS INTEGER)"
+	}
+	return "CAST(strftime('%H', created_at) AS INTEGER)"
+}
+
+// CallsignActivity summarises the hearings of one callsign.
+type CallsignActivity struct {
+	FirstHeard    time.Time `json:"first_heard"`
+	LastHeard     time.Time `json:"last_heard"`
+	Transmissions int64     `json:"transmissions"`
+	// TalkTime is the total duration of all transmissions in seconds
+	TalkTime  float64  `json:"talk_time"`
+	Modules   []string `json:"modules"`
+	Protocols []string `json:"protocols"`
+	// Hours counts transmissions by hour of day (UTC)
+	Hours [24]int64 `json:"hours"`
+}
+
+// CallsignActivity aggregates all hearings of call. Transmissions is zero
+// if the callsign was never heard.
+func (s *Store) CallsignActivity(call string) (CallsignActivity, error) {
+	a := CallsignActivity{Modules: []string{}, Protocols: []string{}}
+	q := func() *gorm.DB { return s.DB.Model(&Hearing{}).Where("my = ?", call) }
+
+	if err := q().Select("COUNT(*) AS transmissions, COALESCE(SUM(duration), 0) AS talk_time").Scan(&a).Error; err != nil {
+		return a, err
+	}
+	if a.Transmissions == 0 {
+		return a, nil
+	}
+	var first, last []time.Time
+	if err := q().Order("created_at").Limit(1).Pluck("created_at", &first).Error; err != nil {
+		return a, err
+	}
+	if err := q().Order("created_at desc").Limit(1).Pluck("created_at", &last).Error; err != nil {
+		return a, err
+	}
+	if len(first) > 0 && len(last) > 0 {
+		a.FirstHeard = first[0].UTC()
+		a.LastHeard = last[0].UTC()
+	}
+
+	var hours []struct {
+		Hour int
+		N    int64
+	}
+	if err := q().Select(s.hourOfDay() + " AS hour, COUNT(*) AS n").Group("hour").Scan(&hours).Error; err != nil {
+		return a, err
+	}
+	for _, h := range hours {
+		if h.Hour >= 0 && h.Hour < len(a.Hours) {
+			a.Hours[h.Hour] = h.N
+		}
+	}
+	if err := q().Distinct().Order("module").Pluck("module", &a.Modules).Error; err != nil {
+		return a, err
+	}
+	if err := q().Distinct().Order("protocol").Pluck("protocol", &a.Protocols).Error; err != nil {
+		return a, err
+	}
+	return a, nil
+}
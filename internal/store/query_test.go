@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestListHearings(t *testing.T) {
+	forEachRepository(t, testListHearings)
+}
+
+func testListHearings(t *testing.T, r HearingRepository) {
+	base := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	seed := []Hearing{
+		{My: "W8CPT", Module: "A", Protocol: "DMR", Duration: 12, CreatedAt: base},
+		{My: "W8EAP", Reflector: "URF002", Module: "B", Protocol: "M17", Duration: 3, CreatedAt: base.Add(time.Hour)},
+		{My: "KF8S", Module: "A", Protocol: "DMR", Duration: 30, CreatedAt: base.Add(2 * time.Hour)},
+		{My: "W8_X", Module: "C", Protocol: "YSF", Duration: 8, CreatedAt: base.Add(3 * time.Hour)},
+	}
+	for i := range seed {
+		if err := r.CreateHearing(&seed[i]); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter HearingFilter
+		want   []string
+	}{
+		{name: "All", filter: HearingFilter{}, want: []string{"W8_X", "KF8S", "W8EAP", "W8CPT"}},
+		{name: "Exact Callsign", filter: HearingFilter{My: "kf8s"}, want: []string{"KF8S"}},
+		{name: "Prefix Wildcard", filter: HearingFilter{My: "W8*"}, want: []string{"W8_X", "W8EAP", "W8CPT"}},
+		{name: "Single Wildcard", filter: HearingFilter{My: "W8??P"}, want: []string{"W8EAP"}},
+		{name: "Underscore Is Literal", filter: HearingFilter{My: "W8_*"}, want: []string{"W8_X"}},
+		{name: "Reflector", filter: HearingFilter{Reflector: "URF002"}, want: []string{"W8EAP"}},
+		{name: "Module and Protocol", filter: HearingFilter{Module: "A", Protocol: "DMR"}, want: []string{"KF8S", "W8CPT"}},
+		{name: "Time Range", filter: HearingFilter{Since: base.Add(time.Hour), Until: base.Add(3 * time.Hour)}, want: []string{"KF8S", "W8EAP"}},
+		{name: "Min Duration", filter: HearingFilter{MinDuration: 10}, want: []string{"KF8S", "W8CPT"}},
+		{name: "Cursor", filter: HearingFilter{BeforeID: seed[2].ID, Limit: 1}, want: []string{"W8EAP"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hearings, err := r.ListHearings(tt.filter)
+			if err != nil {
+				t.Fatalf("ListHearings failed: %v", err)
+			}
+			var got []string
+			for _, h := range hearings {
+				got = append(got, h.My)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	count, err := r.CountHearings(HearingFilter{My: "W8*", BeforeID: seed[1].ID, Limit: 1})
+	if err != nil {
+		t.Fatalf("CountHearings failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected count 3 ignoring the page, got %d", count)
+	}
+}
+
+func TestCallsignActivity(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r HearingRepository) {
+		base := time.Date(2025, 12, 27, 9, 0, 0, 0, time.UTC)
+		for _, h := range []Hearing{
+			{My: "KF8S", Module: "B", Protocol: "M17", Duration: 10, CreatedAt: base},
+			{My: "KF8S", Module: "A", Protocol: "DMR", Duration: 2.5, CreatedAt: base.Add(time.Hour)},
+			{My: "KF8S", Module: "A", Protocol: "DMR", Duration: 1, CreatedAt: base.Add(25 * time.Hour)},
+			{My: "W8CPT", Module: "C", Protocol: "YSF", Duration: 7, CreatedAt: base},
+		} {
+			if err := r.CreateHearing(&h); err != nil {
+				t.Fatalf("Failed to create hearing: %v", err)
+			}
+		}
+
+		a, err := r.CallsignActivity("KF8S")
+		if err != nil {
+			t.Fatalf("CallsignActivity failed: %v", err)
+		}
+		if a.Transmissions != 3 || a.TalkTime != 13.5 {
+			t.Errorf("Expected 3 transmissions and 13.5s, got %d and %v", a.Transmissions, a.TalkTime)
+		}
+		if !a.FirstHeard.Equal(base) || !a.LastHeard.Equal(base.Add(25*time.Hour)) {
+			t.Errorf("Unexpected first/last heard: %v, %v", a.FirstHeard, a.LastHeard)
+		}
+		if len(a.Modules) != 2 || a.Modules[0] != "A" || a.Modules[1] != "B" {
+			t.Errorf("Expected modules [A B], got %v", a.Modules)
+		}
+		if len(a.Protocols) != 2 || a.Protocols[0] != "DMR" || a.Protocols[1] != "M17" {
+			t.Errorf("Expected protocols [DMR M17], got %v", a.Protocols)
+		}
+		if a.Hours[9] != 1 || a.Hours[10] != 2 {
+			t.Errorf("Unexpected hours: %v", a.Hours)
+		}
+
+		// Hours are counted in UTC whatever the zone of the start time
+		east := time.FixedZone("UTC+2", 2*60*60)
+		if err := r.CreateHearing(&Hearing{My: "N8DBF", CreatedAt: base.Add(30 * time.Minute).In(east)}); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+		if a, err := r.CallsignActivity("N8DBF"); err != nil || a.Hours[9] != 1 {
+			t.Errorf("Expected one transmission at 09 UTC, got %v (%v)", a.Hours, err)
+		}
+
+		// The callsign is not a pattern
+		if a, err := r.CallsignActivity("KF8*"); err != nil || a.Transmissions != 0 {
+			t.Errorf("Expected no activity for KF8*, got %d (%v)", a.Transmissions, err)
+		}
+	})
+}
+
+func TestEachHearing(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r HearingRepository) {
+		// More than one chunk
+		n := eachChunk + 2
+		for i := 0; i < n; i++ {
+			module := "A"
+			if i%2 == 1 {
+				module = "B"
+			}
+			if err := r.CreateHearing(&Hearing{My: "KF8S", Module: module}); err != nil {
+				t.Fatalf("Failed to create hearing: %v", err)
+			}
+		}
+
+		var ids []uint
+		err := r.EachHearing(HearingFilter{Module: "A", Limit: 1}, func(h Hearing) error {
+			ids = append(ids, h.ID)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("EachHearing failed: %v", err)
+		}
+		if len(ids) != (n+1)/2 {
+			t.Fatalf("Expected %d hearings ignoring the page, got %d", (n+1)/2, len(ids))
+		}
+		for i := 1; i < len(ids); i++ {
+			if ids[i] <= ids[i-1] {
+				t.Fatalf("Expected oldest first, got %d after %d", ids[i], ids[i-1])
+			}
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = r.EachHearing(HearingFilter{}, func(Hearing) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("Expected to stop after 1 call with the error, got %d calls and %v", calls, err)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package store
+
+// HearingWriter records transmissions as the session tracker follows them.
+type HearingWriter interface {
+	// CreateHearing inserts h, setting its ID.
+	CreateHearing(h *Hearing) error
+	// UpdateModule corrects the module of a transmission in progress.
+	UpdateModule(id uint, module string) error
+	// CloseHearing records the final duration of a transmission in seconds.
+	CloseHearing(id uint, duration float64) error
+}
+
+// HearingRepository stores and queries hearings. Store implements it over
+// GORM; Memory keeps hearings in memory for tests.
+type HearingRepository interface {
+	HearingWriter
+	ListHearings(f HearingFilter) ([]Hearing, error)
+	CountHearings(f HearingFilter) (int64, error)
+	// EachHearing calls fn for every hearing matching f, oldest first,
+	// ignoring its page, and stops at the first error.
+	EachHearing(f HearingFilter, fn func(Hearing) error) error
+	CallsignActivity(call string) (CallsignActivity, error)
+	ModuleActivity(f HearingFilter, bucket Bucket) ([]ModuleActivity, error)
+}
+
+var (
+	_ HearingRepository = (*Store)(nil)
+	_ HearingRepository = (*Memory)(nil)
+)
+
+func (s *Store) CreateHearing(h *Hearing) error {
+	return s.DB.Create(h).Error
+}
+
+func (s *Store) UpdateModule(id uint, module string) error {
+	return s.DB.Model(&Hearing{}).Where("id = ?", id).Update("module", module).Error
+}
+
+func (s *Store) CloseHearing(id uint, duration float64) error {
+	return s.DB.Model(&Hearing{}).Where("id = ?", id).Update("duration", duration).Error
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pruneBatch is the number of hearings deleted per transaction, keeping
+// each write lock short.
+const pruneBatch = 1000
+
+// RetentionPolicy bounds the hearings table. Zero values disable the
+// corresponding limit.
+type RetentionPolicy struct {
+	// MaxAge deletes hearings older than this, along with connections that
+	// ended before then.
+	MaxAge time.Duration
+	// MaxRows keeps at most this many of the newest hearings.
+	MaxRows int
+	// Downsample folds hearings into DailyAggregate rows before deleting
+	// them.
+	Downsample bool
+
+	// Interval is how often RunRetention prunes.
+	Interval time.Duration
+	// CompactInterval is how often RunRetention checkpoints the WAL and,
+	// if anything was pruned since, vacuums the database.
+	CompactInterval time.Duration
+}
+
+// Enabled reports whether the policy limits anything.
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MaxRows > 0
+}
+
+// PruneResult counts the rows removed by Prune.
+type PruneResult struct {
+	Hearings    int64
+	Connections int64
+}
+
+// Prune deletes the hearings outside p as of now, oldest first, in batches.
+func (s *Store) Prune(p RetentionPolicy, now time.Time) (PruneResult, error) {
+	var res PruneResult
+	if !p.Enabled() {
+		return res, nil
+	}
+
+	q := s.DB.Model(&Hearing{})
+	var cutoff time.Time
+	if p.MaxAge > 0 {
+		cutoff = now.Add(-p.MaxAge).UTC()
+	}
+	var keepFrom uint
+	if p.MaxRows > 0 {
+		var ids []uint
+		if err := s.DB.Model(&Hearing{}).Order("id desc").Offset(p.MaxRows-1).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return res, err
+		}
+		if len(ids) > 0 {
+			keepFrom = ids[0]
+		}
+	}
+	switch {
+	case !cutoff.IsZero() && keepFrom > 0:
+		q = q.Where("created_at < ? OR id < ?", cutoff, keepFrom)
+	case !cutoff.IsZero():
+		q = q.Where("created_at < ?", cutoff)
+	case keepFrom > 0:
+		q = q.Where("id < ?", keepFrom)
+	default:
+		// Fewer rows than MaxRows
+		return res, nil
+	}
+
+	for {
+		var batch []Hearing
+		if err := q.Session(&gorm.Session{}).Order("id").Limit(pruneBatch).Find(&batch).Error; err != nil {
+			return res, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if p.Downsample {
+				if err := downsample(tx, batch); err != nil {
+					return err
+				}
+			}
+			ids := make([]uint, len(batch))
+			for i, h := range batch {
+				ids[i] = h.ID
+			}
+			return tx.Delete(&Hearing{}, ids).Error
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Hearings += int64(len(batch))
+	}
+
+	if !cutoff.IsZero() {
+		r := s.DB.Where("disconnected_at IS NOT NULL AND disconnected_at < ?", cutoff).Delete(&Connection{})
+		if r.Error != nil {
+			return res, r.Error
+		}
+		res.Connections = r.RowsAffected
+	}
+	return res, nil
+}
+
+type aggregateKey struct {
+	day       time.Time
+	reflector string
+	callsign  string
+	module    string
+}
+
+// downsample adds hearings to their daily aggregates.
+func downsample(tx *gorm.DB, hearings []Hearing) error {
+	sums := make(map[aggregateKey]*DailyAggregate)
+	var rows []*DailyAggregate
+	for _, h := range hearings {
+		k := aggregateKey{day: BucketDay.Start(h.CreatedAt), reflector: h.Reflector, callsign: h.My, module: h.Module}
+		a := sums[k]
+		if a == nil {
+			a = &DailyAggregate{Day: k.day, Reflector: k.reflector, Callsign: k.callsign, Module: k.module}
+			sums[k] = a
+			rows = append(rows, a)
+		}
+		a.Transmissions++
+		a.TalkTime += h.Duration
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "reflector"}, {Name: "callsign"}, {Name: "module"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"transmissions": gorm.Expr("daily_aggregates.transmissions + excluded.transmissions"),
+			"talk_time":     gorm.Expr("daily_aggregates.talk_time + excluded.talk_time"),
+		}),
+	}).Create(rows).Error
+}
+
+// Compact checkpoints the SQLite write-ahead log and, when vacuum is set,
+// rebuilds the database file to return freed pages to the filesystem.
+func (s *Store) Compact(vacuum bool) error {
+	if s.DB.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	if err := s.DB.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+		return err
+	}
+	if vacuum {
+		return s.DB.Exec("VACUUM").Error
+	}
+	return nil
+}
+
+// RunRetention prunes and compacts the store on p's intervals until ctx is
+// cancelled.
+func (s *Store) RunRetention(ctx context.Context, p RetentionPolicy) {
+	log := zap.L()
+	if !p.Enabled() && p.CompactInterval <= 0 {
+		return
+	}
+
+	var prune, compact <-chan time.Time
+	if p.Enabled() && p.Interval > 0 {
+		t := time.NewTicker(p.Interval)
+		defer t.Stop()
+		prune = t.C
+	}
+	if p.CompactInterval > 0 {
+		t := time.NewTicker(p.CompactInterval)
+		defer t.Stop()
+		compact = t.C
+	}
+
+	pruned := false
+	run := func() {
+		res, err := s.Prune(p, time.Now())
+		if err != nil {
+			log.Error("Failed to prune history", zap.Error(err))
+		}
+		if res.Hearings > 0 || res.Connections > 0 {
+			pruned = true
+			log.Info("Pruned history",
+				zap.Int64("hearings", res.Hearings),
+				zap.Int64("connections", res.Connections))
+		}
+	}
+	if prune != nil {
+		run()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-prune:
+			run()
+		case <-compact:
+			if err := s.Compact(pruned); err != nil {
+				log.Error("Failed to compact database", zap.Error(err))
+				continue
+			}
+			if pruned {
+				log.Info("Vacuumed database")
+			}
+			pruned = false
+		}
+	}
+}
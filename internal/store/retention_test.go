@@ -0,0 +1,121 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2025, 12, 27, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []string // remaining callsigns, oldest first
+	}{
+		{name: "Disabled", policy: RetentionPolicy{}, want: []string{"N0OLD", "N0OLD", "W8CPT", "KF8S", "G4XYZ"}},
+		{name: "Max Age", policy: RetentionPolicy{MaxAge: 7 * day}, want: []string{"W8CPT", "KF8S", "G4XYZ"}},
+		{name: "Max Rows", policy: RetentionPolicy{MaxRows: 2}, want: []string{"KF8S", "G4XYZ"}},
+		{name: "Max Rows Above Count", policy: RetentionPolicy{MaxRows: 10}, want: []string{"N0OLD", "N0OLD", "W8CPT", "KF8S", "G4XYZ"}},
+		{name: "Both", policy: RetentionPolicy{MaxAge: 7 * day, MaxRows: 4}, want: []string{"W8CPT", "KF8S", "G4XYZ"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachDriver(t, func(t *testing.T, s *Store) {
+				for _, h := range []Hearing{
+					{My: "N0OLD", Module: "A", CreatedAt: now.Add(-30 * day), Duration: 10},
+					{My: "N0OLD", Module: "A", CreatedAt: now.Add(-30*day + time.Hour), Duration: 5},
+					{My: "W8CPT", Module: "B", CreatedAt: now.Add(-2 * day)},
+					{My: "KF8S", Module: "A", CreatedAt: now.Add(-time.Hour)},
+					{My: "G4XYZ", Module: "C", CreatedAt: now},
+				} {
+					if err := s.DB.Create(&h).Error; err != nil {
+						t.Fatalf("Failed to create hearing: %v", err)
+					}
+				}
+
+				res, err := s.Prune(tt.policy, now)
+				if err != nil {
+					t.Fatalf("Prune failed: %v", err)
+				}
+				var calls []string
+				s.DB.Model(&Hearing{}).Order("id").Pluck("my", &calls)
+				if len(calls) != len(tt.want) {
+					t.Fatalf("Expected %v, got %v", tt.want, calls)
+				}
+				for i := range calls {
+					if calls[i] != tt.want[i] {
+						t.Errorf("Expected %v, got %v", tt.want, calls)
+						break
+					}
+				}
+				if want := int64(5 - len(tt.want)); res.Hearings != want {
+					t.Errorf("Expected %d pruned, got %d", want, res.Hearings)
+				}
+			})
+		})
+	}
+}
+
+func TestPruneDownsample(t *testing.T) {
+	forEachDriver(t, testPruneDownsample)
+}
+
+func testPruneDownsample(t *testing.T, s *Store) {
+	day := time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)
+	linkedAt := day.Add(-time.Hour)
+	if err := s.DB.Create(&Connection{Kind: KindClient, Callsign: "KF8S", ConnectedAt: linkedAt, DisconnectedAt: &day}).Error; err != nil {
+		t.Fatalf("Failed to create connection: %v", err)
+	}
+	if err := s.DB.Create(&Connection{Kind: KindClient, Callsign: "W8CPT", ConnectedAt: linkedAt}).Error; err != nil {
+		t.Fatalf("Failed to create connection: %v", err)
+	}
+
+	policy := RetentionPolicy{MaxAge: 24 * time.Hour, Downsample: true}
+	// Two rounds so the second adds to the aggregate from the first
+	for i, at := range []time.Time{day.Add(8 * time.Hour), day.Add(20 * time.Hour)} {
+		for _, h := range []Hearing{
+			{My: "KF8S", Module: "A", CreatedAt: at, Duration: 10},
+			{My: "KF8S", Module: "A", CreatedAt: at.Add(time.Minute), Duration: 2.5},
+			{My: "KF8S", Module: "B", CreatedAt: at, Duration: 1},
+			{Reflector: "URF002", My: "KF8S", Module: "A", CreatedAt: at, Duration: 4},
+		} {
+			if err := s.DB.Create(&h).Error; err != nil {
+				t.Fatalf("Failed to create hearing: %v", err)
+			}
+		}
+		if _, err := s.Prune(policy, day.AddDate(0, 0, 2+i)); err != nil {
+			t.Fatalf("Prune failed: %v", err)
+		}
+	}
+
+	var aggs []DailyAggregate
+	if err := s.DB.Order("module, reflector").Find(&aggs).Error; err != nil {
+		t.Fatalf("Failed to load aggregates: %v", err)
+	}
+	if len(aggs) != 3 {
+		t.Fatalf("Expected 3 aggregates, got %+v", aggs)
+	}
+	if a := aggs[0]; a.Module != "A" || a.Reflector != "" || a.Transmissions != 4 || a.TalkTime != 25 || !a.Day.Equal(day) {
+		t.Errorf("Unexpected aggregate for A: %+v", a)
+	}
+	if a := aggs[1]; a.Module != "A" || a.Reflector != "URF002" || a.Transmissions != 2 || a.TalkTime != 8 {
+		t.Errorf("Unexpected aggregate for A on URF002: %+v", a)
+	}
+	if a := aggs[2]; a.Module != "B" || a.Transmissions != 2 || a.TalkTime != 2 {
+		t.Errorf("Unexpected aggregate for B: %+v", a)
+	}
+
+	// Only the ended connection is old enough to go
+	var conns []Connection
+	s.DB.Find(&conns)
+	if len(conns) != 1 || conns[0].Callsign != "W8CPT" {
+		t.Errorf("Expected only the open connection to remain, got %+v", conns)
+	}
+
+	if err := s.Compact(true); err != nil {
+		t.Errorf("Compact failed: %v", err)
+	}
+}
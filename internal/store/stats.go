@@ -0,0 +1,148 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Bucket is the width of a time-series bucket.
+type Bucket string
+
+const (
+	BucketHour Bucket = "hour"
+	BucketDay  Bucket = "day"
+	BucketWeek Bucket = "week"
+)
+
+// ParseBucket validates a bucket name.
+func ParseBucket(s string) (Bucket, error) {
+	switch b := Bucket(s); b {
+	case BucketHour, BucketDay, BucketWeek:
+		return b, nil
+	}
+	return "", fmt.Errorf("invalid bucket: %q", s)
+}
+
+// Start returns the start of the bucket containing t, in UTC. Weeks start
+// on Monday.
+func (b Bucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Activity counts transmissions, distinct callsigns and airtime (seconds).
+type Activity struct {
+	Transmissions   int64   `json:"transmissions"`
+	UniqueCallsigns int     `json:"unique_callsigns"`
+	Airtime         float64 `json:"airtime"`
+}
+
+// BucketActivity is the Activity within one bucket starting at Start.
+type BucketActivity struct {
+	Start time.Time `json:"start"`
+	Activity
+}
+
+// ModuleActivity is the Activity of one module over the whole range and
+// per bucket. Buckets without transmissions are omitted.
+type ModuleActivity struct {
+	Module string `json:"module"`
+	Activity
+	Buckets []BucketActivity `json:"buckets"`
+}
+
+// activityCounter accumulates Activity, tracking callsigns to count them
+// once.
+type activityCounter struct {
+	Activity
+	calls map[string]struct{}
+}
+
+func (c *activityCounter) add(call string, duration float64) {
+	if c.calls == nil {
+		c.calls = make(map[string]struct{})
+	}
+	c.Transmissions++
+	c.Airtime += duration
+	c.calls[call] = struct{}{}
+	c.UniqueCallsigns = len(c.calls)
+}
+
+// ModuleActivity aggregates the hearings matching f per module and per
+// bucket, ordered by module name and bucket start. Rows are streamed, so
+// only the counters are held in memory.
+func (s *Store) ModuleActivity(f HearingFilter, bucket Bucket) ([]ModuleActivity, error) {
+	rows, err := f.where(s.DB.Model(&Hearing{})).
+		Select("created_at, module, my, duration").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	agg := newModuleAggregator(bucket)
+	for rows.Next() {
+		var h Hearing
+		if err := s.DB.ScanRows(rows, &h); err != nil {
+			return nil, err
+		}
+		agg.add(h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return agg.result(), nil
+}
+
+// moduleAggregator accumulates ModuleActivity from hearings in any order.
+type moduleAggregator struct {
+	bucket  Bucket
+	totals  map[string]*activityCounter
+	buckets map[string]map[time.Time]*activityCounter
+}
+
+func newModuleAggregator(bucket Bucket) *moduleAggregator {
+	return &moduleAggregator{
+		bucket:  bucket,
+		totals:  make(map[string]*activityCounter),
+		buckets: make(map[string]map[time.Time]*activityCounter),
+	}
+}
+
+func (a *moduleAggregator) add(h Hearing) {
+	if a.totals[h.Module] == nil {
+		a.totals[h.Module] = &activityCounter{}
+		a.buckets[h.Module] = make(map[time.Time]*activityCounter)
+	}
+	a.totals[h.Module].add(h.My, h.Duration)
+
+	start := a.bucket.Start(h.CreatedAt)
+	if a.buckets[h.Module][start] == nil {
+		a.buckets[h.Module][start] = &activityCounter{}
+	}
+	a.buckets[h.Module][start].add(h.My, h.Duration)
+}
+
+// result returns the activity ordered by module name and bucket start.
+func (a *moduleAggregator) result() []ModuleActivity {
+	out := make([]ModuleActivity, 0, len(a.totals))
+	for module, total := range a.totals {
+		m := ModuleActivity{Module: module, Activity: total.Activity}
+		for start, c := range a.buckets[module] {
+			m.Buckets = append(m.Buckets, BucketActivity{Start: start, Activity: c.Activity})
+		}
+		sort.Slice(m.Buckets, func(i, j int) bool { return m.Buckets[i].Start.Before(m.Buckets[j].Start) })
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Module < out[j].Module })
+	return out
+}
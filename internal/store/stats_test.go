@@ -0,0 +1,65 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketStart(t *testing.T) {
+	// Saturday afternoon
+	ts := time.Date(2025, 12, 27, 15, 42, 10, 0, time.UTC)
+	tests := []struct {
+		bucket Bucket
+		want   time.Time
+	}{
+		{BucketHour, time.Date(2025, 12, 27, 15, 0, 0, 0, time.UTC)},
+		{BucketDay, time.Date(2025, 12, 27, 0, 0, 0, 0, time.UTC)},
+		{BucketWeek, time.Date(2025, 12, 22, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.bucket.Start(ts); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.bucket, tt.want, got)
+		}
+	}
+
+	// A Monday starts its own week
+	monday := time.Date(2025, 12, 22, 8, 0, 0, 0, time.UTC)
+	if got := BucketWeek.Start(monday); !got.Equal(time.Date(2025, 12, 22, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Monday to start its week, got %v", got)
+	}
+
+	if _, err := ParseBucket("month"); err == nil {
+		t.Errorf("Expected error for unknown bucket")
+	}
+}
+
+func TestModuleActivity(t *testing.T) {
+	forEachRepository(t, func(t *testing.T, r HearingRepository) {
+		base := time.Date(2025, 12, 27, 9, 0, 0, 0, time.UTC)
+		for _, h := range []Hearing{
+			{My: "KF8S", Module: "A", Duration: 10, CreatedAt: base},
+			{My: "KF8S", Module: "A", Duration: 5, CreatedAt: base.Add(30 * time.Minute)},
+			{My: "W8CPT", Module: "A", Duration: 1, CreatedAt: base.Add(2 * time.Hour)},
+			{My: "W8CPT", Module: "B", Duration: 7, CreatedAt: base},
+		} {
+			if err := r.CreateHearing(&h); err != nil {
+				t.Fatalf("Failed to create hearing: %v", err)
+			}
+		}
+
+		got, err := r.ModuleActivity(HearingFilter{}, BucketHour)
+		if err != nil {
+			t.Fatalf("ModuleActivity failed: %v", err)
+		}
+		if len(got) != 2 || got[0].Module != "A" || got[1].Module != "B" {
+			t.Fatalf("Expected modules A and B, got %+v", got)
+		}
+		a := got[0]
+		if a.Transmissions != 3 || a.UniqueCallsigns != 2 || a.Airtime != 16 {
+			t.Errorf("Unexpected totals for A: %+v", a.Activity)
+		}
+		if len(a.Buckets) != 2 || !a.Buckets[0].Start.Equal(base) || a.Buckets[0].Transmissions != 2 {
+			t.Errorf("Unexpected buckets for A: %+v", a.Buckets)
+		}
+	})
+}
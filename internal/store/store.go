@@ -1,11 +1,14 @@
 package store
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
@@ -14,15 +17,75 @@ type Store struct {
 	DB *gorm.DB
 }
 
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Config selects the database. DSN is a file path for SQLite and a
+// connection string for PostgreSQL.
+type Config struct {
+	Driver string
+	DSN    string
+}
+
+// NewStore opens the SQLite database at dbPath and applies any pending
+// migrations.
 func NewStore(dbPath string) (*Store, error) {
+	return New(Config{Driver: DriverSQLite, DSN: dbPath})
+}
+
+// New opens the database described by cfg and applies any pending
+// migrations.
+func New(cfg Config) (*Store, error) {
+	s, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := s.Migrate()
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open opens the SQLite database at dbPath without migrating it.
+func Open(dbPath string) (*Store, error) {
+	return Connect(Config{Driver: DriverSQLite, DSN: dbPath})
+}
+
+// Connect opens the database described by cfg without migrating it.
+func Connect(cfg Config) (*Store, error) {
+	gormCfg := &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+	}
+
+	switch cfg.Driver {
+	case DriverSQLite, "":
+		return openSQLite(cfg.DSN, gormCfg)
+	case DriverPostgres:
+		db, err := gorm.Open(postgres.Open(cfg.DSN), gormCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &Store{DB: db}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+func openSQLite(dbPath string, gormCfg *gorm.Config) (*Store, error) {
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, err
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+	db, err := gorm.Open(sqlite.Open(dbPath), gormCfg)
 	if err != nil {
 		return nil, err
 	}
@@ -36,10 +99,23 @@ func NewStore(dbPath string) (*Store, error) {
 		log.Printf("Failed to set WAL mode: %v", err)
 	}
 
-	// Auto Migrate
-	if err := db.AutoMigrate(&Hearing{}); err != nil {
-		return nil, err
+	return &Store{DB: db}, nil
+}
+
+// Close closes the underlying database handle.
+func (s *Store) Close() error {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
 	}
+	return sqlDB.Close()
+}
 
-	return &Store{DB: db}, nil
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
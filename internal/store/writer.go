@@ -0,0 +1,332 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/dbehnke/urfd-nng-dashboard/internal/metrics"
+)
+
+// Defaults for BatchWriter
+const (
+	DefaultWriteInterval = 250 * time.Millisecond
+	DefaultWriteBatch    = 100
+)
+
+// A failed batch is retried writeRetries times, retryDelay apart and
+// doubling, before its writes are replayed one at a time.
+const (
+	writeRetries = 3
+	retryDelay   = 50 * time.Millisecond
+)
+
+// ErrWriterClosed is returned by writes after Close.
+var ErrWriterClosed = errors.New("hearing writer closed")
+
+type opKind int
+
+const (
+	opCreate opKind = iota
+	opModule
+	opDuration
+)
+
+// writeOp is one queued HearingWriter call.
+type writeOp struct {
+	kind     opKind
+	hearing  Hearing
+	id       uint
+	module   string
+	duration float64
+}
+
+// BatchWriter is a HearingWriter that queues writes and applies them from a
+// background goroutine, batched in transactions and in the order they were
+// made. CreateHearing assigns the ID up front, so callers can broadcast it
+// before the row is written.
+type BatchWriter struct {
+	store    *Store
+	ids      idAllocator
+	interval time.Duration
+	batch    int
+	log      *zap.Logger
+
+	mu     sync.Mutex
+	ops    []writeOp
+	closed bool
+	// flushing is the number of writes in the transaction under way
+	flushing int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBatchWriter starts a writer flushing every interval, or as soon as
+// batch writes are queued.
+func NewBatchWriter(s *Store, interval time.Duration, batch int) (*BatchWriter, error) {
+	ids, err := newIDAllocator(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultWriteInterval
+	}
+	if batch <= 0 {
+		batch = DefaultWriteBatch
+	}
+	w := &BatchWriter{
+		store:    s,
+		ids:      ids,
+		interval: interval,
+		batch:    batch,
+		log:      zap.L(),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *BatchWriter) CreateHearing(h *Hearing) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+
+	id, err := w.ids.next()
+	if err != nil {
+		return err
+	}
+	h.ID = id
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+	w.enqueue(writeOp{kind: opCreate, hearing: *h})
+	return nil
+}
+
+func (w *BatchWriter) UpdateModule(id uint, module string) error {
+	return w.push(writeOp{kind: opModule, id: id, module: module})
+}
+
+func (w *BatchWriter) CloseHearing(id uint, duration float64) error {
+	return w.push(writeOp{kind: opDuration, id: id, duration: duration})
+}
+
+func (w *BatchWriter) push(op writeOp) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrWriterClosed
+	}
+	w.enqueue(op)
+	return nil
+}
+
+// enqueue queues op, waking the writer once a batch is full. Callers must
+// hold w.mu.
+func (w *BatchWriter) enqueue(op writeOp) {
+	w.ops = append(w.ops, op)
+	if len(w.ops) >= w.batch {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending returns the number of writes not yet committed.
+func (w *BatchWriter) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.ops) + w.flushing
+}
+
+// Close stops accepting writes and returns once everything queued has
+// been flushed.
+func (w *BatchWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		<-w.done
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		case <-w.stop:
+			w.flush()
+			return
+		}
+		w.flush()
+	}
+}
+
+// flush writes everything queued, up to batch writes per transaction. A
+// batch that still fails after its retries is replayed one write at a time,
+// so only the writes that fail on their own are logged and dropped and the
+// queue keeps moving.
+func (w *BatchWriter) flush() {
+	for {
+		w.mu.Lock()
+		ops := w.ops
+		if len(ops) > w.batch {
+			ops = ops[:w.batch]
+		}
+		w.ops = w.ops[len(ops):]
+		w.flushing = len(ops)
+		w.mu.Unlock()
+		if len(ops) == 0 {
+			return
+		}
+
+		if err := w.commit(ops); err != nil {
+			w.log.Warn("Failed to write hearing batch, writing one at a time", zap.Int("writes", len(ops)), zap.Error(err))
+			w.replay(ops)
+		}
+		w.mu.Lock()
+		w.flushing = 0
+		w.mu.Unlock()
+	}
+}
+
+// commit applies ops in one transaction, retrying on failure.
+func (w *BatchWriter) commit(ops []writeOp) error {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err := w.store.DB.Transaction(func(tx *gorm.DB) error { return apply(tx, ops) })
+		metrics.DBWriteSeconds.WithLabelValues("batch").Observe(time.Since(start).Seconds())
+		if err == nil || attempt == writeRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// replay applies ops one at a time in order, dropping those that fail.
+func (w *BatchWriter) replay(ops []writeOp) {
+	for _, op := range ops {
+		err := w.store.DB.Transaction(func(tx *gorm.DB) error { return apply(tx, []writeOp{op}) })
+		if err != nil {
+			id := op.id
+			if op.kind == opCreate {
+				id = op.hearing.ID
+			}
+			w.log.Error("Failed to write hearing", zap.Uint("id", id), zap.Error(err))
+		}
+	}
+}
+
+// apply performs ops in order. Updates to hearings created in the same
+// batch are folded into the insert.
+func apply(tx *gorm.DB, ops []writeOp) error {
+	var inserts []*Hearing
+	created := make(map[uint]*Hearing)
+	var updates []writeOp
+	for _, op := range ops {
+		switch op.kind {
+		case opCreate:
+			h := op.hearing
+			inserts = append(inserts, &h)
+			created[h.ID] = &h
+		case opModule:
+			if h := created[op.id]; h != nil {
+				h.Module = op.module
+			} else {
+				updates = append(updates, op)
+			}
+		case opDuration:
+			if h := created[op.id]; h != nil {
+				h.Duration = op.duration
+			} else {
+				updates = append(updates, op)
+			}
+		}
+	}
+
+	if len(inserts) > 0 {
+		if err := tx.Create(inserts).Error; err != nil {
+			return err
+		}
+	}
+	// Updates only touch rows written by earlier batches, so running them
+	// after the inserts keeps each session's writes in order.
+	for _, op := range updates {
+		q := tx.Model(&Hearing{}).Where("id = ?", op.id)
+		var err error
+		if op.kind == opModule {
+			err = q.Update("module", op.module).Error
+		} else {
+			err = q.Update("duration", op.duration).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idAllocator hands out hearing IDs ahead of the insert.
+type idAllocator interface {
+	next() (uint, error)
+}
+
+func newIDAllocator(db *gorm.DB) (idAllocator, error) {
+	if db.Dialector.Name() == DriverPostgres {
+		return &sequenceIDs{db: db}, nil
+	}
+	// SQLite has a single writer, so counting on from the largest ID ever
+	// used is safe. sqlite_sequence remembers it after retention has
+	// emptied the table, so IDs are never reused, as with AUTOINCREMENT.
+	var last uint
+	err := db.Raw("SELECT MAX(COALESCE((SELECT MAX(id) FROM hearings), 0), COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'hearings'), 0))").
+		Scan(&last).Error
+	if err != nil {
+		return nil, err
+	}
+	return &counterIDs{last: last}, nil
+}
+
+type counterIDs struct {
+	last uint
+}
+
+func (c *counterIDs) next() (uint, error) {
+	c.last++
+	return c.last, nil
+}
+
+// sequenceIDs takes each ID from the hearings sequence as the hearing
+// starts, so hearings of several dashboards sharing one PostgreSQL database
+// stay in time order by ID, as history paging expects.
+type sequenceIDs struct {
+	db *gorm.DB
+}
+
+func (s *sequenceIDs) next() (uint, error) {
+	var id uint
+	err := s.db.Raw("SELECT nextval(pg_get_serial_sequence('hearings', 'id'))").Scan(&id).Error
+	return id, err
+}
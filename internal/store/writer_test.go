@@ -0,0 +1,187 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatchWriter(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *Store) {
+		existing := &Hearing{My: "G4XYZ", Module: "A"}
+		if err := s.CreateHearing(existing); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+
+		// Only a full batch or Close flushes
+		w, err := NewBatchWriter(s, time.Hour, 3)
+		if err != nil {
+			t.Fatalf("Failed to create writer: %v", err)
+		}
+
+		first := &Hearing{My: "KF8S", Module: "A"}
+		second := &Hearing{My: "W8ABC", Module: "B"}
+		for _, h := range []*Hearing{first, second} {
+			if err := w.CreateHearing(h); err != nil {
+				t.Fatalf("Failed to queue hearing: %v", err)
+			}
+		}
+		if first.ID <= existing.ID || second.ID <= first.ID {
+			t.Fatalf("Expected increasing IDs after %d, got %d and %d", existing.ID, first.ID, second.ID)
+		}
+		if n := w.Pending(); n != 2 {
+			t.Errorf("Expected 2 pending writes, got %d", n)
+		}
+
+		// Folded into the insert of the first batch
+		if err := w.UpdateModule(first.ID, "C"); err != nil {
+			t.Fatalf("Failed to queue module update: %v", err)
+		}
+		waitPending(t, w)
+		var count int64
+		s.DB.Model(&Hearing{}).Count(&count)
+		if count != 3 {
+			t.Errorf("Expected 3 hearings after a full batch, got %d", count)
+		}
+
+		// Updates to rows from an earlier batch
+		if err := w.CloseHearing(first.ID, 4.5); err != nil {
+			t.Fatalf("Failed to queue close: %v", err)
+		}
+		if err := w.UpdateModule(second.ID, "D"); err != nil {
+			t.Fatalf("Failed to queue module update: %v", err)
+		}
+		if err := w.CloseHearing(second.ID, 2); err != nil {
+			t.Fatalf("Failed to queue close: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close writer: %v", err)
+		}
+
+		tests := []struct {
+			id       uint
+			my       string
+			module   string
+			duration float64
+		}{
+			{existing.ID, "G4XYZ", "A", 0},
+			{first.ID, "KF8S", "C", 4.5},
+			{second.ID, "W8ABC", "D", 2},
+		}
+		for _, tt := range tests {
+			var h Hearing
+			if err := s.DB.First(&h, tt.id).Error; err != nil {
+				t.Fatalf("Failed to load hearing %d: %v", tt.id, err)
+			}
+			if h.My != tt.my || h.Module != tt.module || h.Duration != tt.duration {
+				t.Errorf("Expected %s on %s for %v, got %s on %s for %v",
+					tt.my, tt.module, tt.duration, h.My, h.Module, h.Duration)
+			}
+		}
+
+		if err := w.CreateHearing(&Hearing{My: "N0CALL"}); !errors.Is(err, ErrWriterClosed) {
+			t.Errorf("Expected ErrWriterClosed, got %v", err)
+		}
+
+		// Inserts without the writer keep working
+		after := &Hearing{My: "N0CALL", Module: "A"}
+		if err := s.CreateHearing(after); err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+		if after.ID <= second.ID {
+			t.Errorf("Expected an ID after %d, got %d", second.ID, after.ID)
+		}
+	})
+}
+
+func TestBatchWriterBadWrite(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *Store) {
+		w, err := NewBatchWriter(s, time.Hour, 10)
+		if err != nil {
+			t.Fatalf("Failed to create writer: %v", err)
+		}
+		first := &Hearing{My: "KF8S", Module: "A"}
+		if err := w.CreateHearing(first); err != nil {
+			t.Fatalf("Failed to queue hearing: %v", err)
+		}
+		// Takes the ID the writer hands out next, so that insert fails
+		if err := s.DB.Create(&Hearing{ID: first.ID + 1, My: "G4XYZ"}).Error; err != nil {
+			t.Fatalf("Failed to create hearing: %v", err)
+		}
+		bad := &Hearing{My: "W8ABC", Module: "B"}
+		last := &Hearing{My: "N0CALL", Module: "C"}
+		for _, h := range []*Hearing{bad, last} {
+			if err := w.CreateHearing(h); err != nil {
+				t.Fatalf("Failed to queue hearing: %v", err)
+			}
+		}
+		if bad.ID != first.ID+1 {
+			t.Fatalf("Expected ID %d to collide, got %d", first.ID+1, bad.ID)
+		}
+		if err := w.CloseHearing(first.ID, 3); err != nil {
+			t.Fatalf("Failed to queue close: %v", err)
+		}
+		if err := w.CloseHearing(last.ID, 5); err != nil {
+			t.Fatalf("Failed to queue close: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Failed to close writer: %v", err)
+		}
+
+		// Only the colliding insert is lost
+		var hearings []Hearing
+		s.DB.Order("id").Find(&hearings)
+		if len(hearings) != 3 {
+			t.Fatalf("Expected 3 hearings, got %+v", hearings)
+		}
+		for i, want := range []struct {
+			my       string
+			duration float64
+		}{{"KF8S", 3}, {"G4XYZ", 0}, {"N0CALL", 5}} {
+			if h := hearings[i]; h.My != want.my || h.Duration != want.duration {
+				t.Errorf("Expected %s for %v, got %s for %v", want.my, want.duration, h.My, h.Duration)
+			}
+		}
+	})
+}
+
+func TestBatchWriterIDsNotReused(t *testing.T) {
+	forEachDriver(t, func(t *testing.T, s *Store) {
+		create := func() uint {
+			t.Helper()
+			w, err := NewBatchWriter(s, time.Hour, 10)
+			if err != nil {
+				t.Fatalf("Failed to create writer: %v", err)
+			}
+			h := &Hearing{My: "KF8S", Module: "A"}
+			if err := w.CreateHearing(h); err != nil {
+				t.Fatalf("Failed to queue hearing: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Failed to close writer: %v", err)
+			}
+			return h.ID
+		}
+
+		first := create()
+		// Retention empties the table, then the dashboard restarts
+		if err := s.DB.Where("1 = 1").Delete(&Hearing{}).Error; err != nil {
+			t.Fatalf("Failed to delete hearings: %v", err)
+		}
+		if next := create(); next <= first {
+			t.Errorf("Expected an ID after %d, got %d", first, next)
+		}
+	})
+}
+
+// waitPending waits for w to commit everything queued.
+func waitPending(t *testing.T, w *BatchWriter) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for w.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out with %d writes pending", w.Pending())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
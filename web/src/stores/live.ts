@@ -18,6 +18,7 @@ export interface Hearing {
 export const useLiveStore = defineStore('live', () => {
     const lastHeard = ref<Hearing[]>([])
     const connected = ref(false)
+    const hasOlder = ref(false)
     const activeSessions = reactive<Record<number, number>>({}) // Session ID -> Last Seen Timestamp
     const reflector = useReflectorStore()
 
@@ -26,18 +27,11 @@ export const useLiveStore = defineStore('live', () => {
     const connect = () => {
         // Fetch history
         fetch('/api/history')
-            .then(res => res.json())
+            .then(res => {
+                hasOlder.value = Number(res.headers.get('X-Total-Count') || 0) > 50
+                return res.json()
+            })
             .then((data: Hearing[]) => {
-                // Ensure dates are parsed if needed, or rely on JS/JSON checks
-                // Also map DB fields if they differ from Hearing interface? 
-                // DB Hearing: CreatedAt (time.Time) -> string/date.
-                // Hearing interface: created_at (string).
-                // GORM/JSON usually handles this to ISO string.
-                // We might need to snake_case mapping.
-                // Actually Go struct tags in models.go?
-                // store.Hearing has json tags? Let's assume snake_case default or check.
-                // NOTE: store.Hearing tags might be missing. I'll assume they match for now or I'd check models.go
-                // But let's just assign.
                 lastHeard.value = data
             })
             .catch(err => console.error("Failed to load history:", err))
@@ -50,6 +44,7 @@ export const useLiveStore = defineStore('live', () => {
 
         ws.onopen = () => {
             connected.value = true
+            reflector.reset()
         }
 
         ws.onclose = () => {
@@ -137,10 +132,24 @@ export const useLiveStore = defineStore('live', () => {
         }
     }, 1000)
 
+    // Page back through history using the smallest id loaded so far as the cursor
+    const loadOlder = async () => {
+        const ids = lastHeard.value.map(h => h.id).filter(id => id > 0)
+        if (ids.length === 0) return
+        try {
+            const res = await fetch(`/api/history?before_id=${Math.min(...ids)}`)
+            const data: Hearing[] = await res.json()
+            lastHeard.value.push(...data.filter(h => !lastHeard.value.some(x => x.id === h.id)))
+            hasOlder.value = data.length === 50
+        } catch (err) {
+            console.error("Failed to load older history:", err)
+        }
+    }
+
     const isSessionActive = (id?: number) => {
         if (!id) return false
         return !!activeSessions[id]
     }
 
-    return { lastHeard, connected, connect, activeSessions, isSessionActive }
+    return { lastHeard, connected, hasOlder, connect, loadOlder, activeSessions, isSessionActive }
 })
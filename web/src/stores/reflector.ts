@@ -1,5 +1,5 @@
 import { defineStore } from 'pinia'
-import { ref } from 'vue'
+import { computed, ref } from 'vue'
 
 export interface Client {
     Callsign: string
@@ -10,6 +10,7 @@ export interface Client {
 
 export interface User {
     Callsign: string
+    Repeater?: string
     LastHeard: string
     OnModule: string
     ViaPeer: string
@@ -26,27 +27,121 @@ export interface Module {
     Description: string
 }
 
+// Changes to one list of the state, as sent in 'state_diff' messages
+interface Delta<T> {
+    added?: T[]
+    updated?: T[]
+    removed?: string[]
+}
+
+// Entry keys, matching the server's internal/state package
+const clientKey = (c: Client) => `${c.Callsign}|${c.OnModule}`
+const userKey = (u: User) => `${u.Callsign}|${u.Repeater ?? ''}`
+const peerKey = (p: Peer) => p.Callsign
+const moduleKey = (m: Module) => m.Name
+
+const applyDelta = <T>(list: T[], delta: Delta<T> | undefined, key: (v: T) => string): T[] => {
+    if (!delta) return list
+    const removed = new Set(delta.removed || [])
+    const updated = new Map((delta.updated || []).map(v => [key(v), v] as [string, T]))
+    const next = list
+        .filter(v => !removed.has(key(v)))
+        .map(v => updated.get(key(v)) ?? v)
+    next.push(...(delta.added || []))
+    return next
+}
+
+type LinkStatus = 'connecting' | 'connected' | 'stale'
+
+// State of one reflector, kept apart since each numbers its own diffs
+interface ReflectorState {
+    clients: Client[]
+    users: User[]
+    peers: Peer[]
+    modules: Module[]
+    config: Record<string, any>
+    // Status of the dashboard's NNG link to the reflector
+    link: LinkStatus
+    // Sequence number of the last state_diff applied
+    seq: number
+}
+
+const emptyState = (): ReflectorState => ({
+    clients: [], users: [], peers: [], modules: [], config: {}, link: 'connecting', seq: 0,
+})
+
 export const useReflectorStore = defineStore('reflector', () => {
-    const clients = ref<Client[]>([])
-    const users = ref<User[]>([])
-    const peers = ref<Peer[]>([])
-    const modules = ref<Module[]>([])
-    const config = ref<Record<string, any>>({})
+    // By reflector ID, as carried in each event's reflector field
+    const states = ref<Record<string, ReflectorState>>({})
+    // The reflector shown; the first one heard from until one is picked
+    const selected = ref<string | null>(null)
 
+    const ids = computed(() => Object.keys(states.value).sort())
+    const current = computed(() => states.value[selected.value ?? ''] ?? emptyState())
+    const clients = computed(() => current.value.clients)
+    const users = computed(() => current.value.users)
+    const peers = computed(() => current.value.peers)
+    const modules = computed(() => current.value.modules)
+    const config = computed(() => current.value.config)
+    const link = computed(() => current.value.link)
+
+    const stateOf = (id: string): ReflectorState => {
+        if (!states.value[id]) states.value[id] = emptyState()
+        if (selected.value === null) selected.value = id
+        return states.value[id]!
+    }
+
+    const select = (id: string) => { selected.value = id }
+
+    // Snapshots are complete: empty lists are omitted by the server
     const updateState = (state: any) => {
-        if (state.Clients) clients.value = state.Clients
-        if (state.Users) users.value = state.Users
-        if (state.Peers) peers.value = state.Peers
-        if (state.Modules) modules.value = state.Modules
-        if (state.Configure) config.value = state.Configure
+        const st = stateOf(state.reflector || '')
+        st.clients = state.Clients || []
+        st.users = state.Users || []
+        st.peers = state.Peers || []
+        st.modules = state.Modules || []
+        if (state.Configure) st.config = state.Configure
+        st.seq = state.seq || 0
+    }
+
+    // Called on each websocket (re)connect; the server follows up with a
+    // snapshot of every reflector
+    const reset = () => { states.value = {} }
+
+    const resync = (id: string) => {
+        fetch(`/api/state?reflector=${encodeURIComponent(id)}`)
+            .then(res => res.ok ? res.json() : null)
+            .then(state => { if (state) updateState(state) })
+            .catch(err => console.error("Failed to load state:", err))
+    }
+
+    const applyDiff = (diff: any) => {
+        const id = diff.reflector || ''
+        const st = stateOf(id)
+        if (diff.seq <= st.seq) return // Already included in our snapshot
+        if (diff.seq !== st.seq + 1) {
+            // Missed a diff, start over from a fresh snapshot
+            resync(id)
+            return
+        }
+        st.clients = applyDelta(st.clients, diff.clients, clientKey)
+        st.users = applyDelta(st.users, diff.users, userKey)
+        st.peers = applyDelta(st.peers, diff.peers, peerKey)
+        st.modules = applyDelta(st.modules, diff.modules, moduleKey)
+        if (diff.configure) st.config = diff.configure
+        st.seq = diff.seq
     }
 
     const handleEvent = (ev: any) => {
         if (ev.type === 'state') {
             updateState(ev)
+        } else if (ev.type === 'state_diff') {
+            applyDiff(ev)
+        } else if (ev.type === 'connection') {
+            stateOf(ev.reflector || '').link = ev.status
         }
         // We can also handle client_connect/disconnect incrementally here
     }
 
-    return { clients, users, peers, modules, config, handleEvent }
+    return { ids, selected, select, clients, users, peers, modules, config, link, reset, handleEvent }
 })